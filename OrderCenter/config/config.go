@@ -1,17 +1,109 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	MySQLDSN    string
 	RabbitMQURL string
+	Topology    *Topology
+
+	// 发布消息的压缩：none / gzip / zstd，消息体达到阈值（字节）才压缩
+	Compression          string
+	CompressionThreshold int
+
+	// 调度订单轮询间隔
+	SchedulerInterval time.Duration
+	// 事件发件箱的轮询间隔，推送失败时在此基础上退避
+	OutboxRelayInterval time.Duration
+
+	// 订单默认有效期（0 表示不过期），以及按策略覆盖的有效期
+	DefaultOrderTTL  time.Duration
+	StrategyOrderTTL map[int64]time.Duration
+	// 过期清理任务间隔
+	ExpirySweepInterval time.Duration
+	// 按状态统计的订单数指标的缓存时间
+	OrderStatusMetricsTTL time.Duration
+
+	// 实例标识，用于主节点选举
+	InstanceID string
+	// 领导权租约时长和续约间隔
+	LeaderLeaseTTL      time.Duration
+	LeaderRenewInterval time.Duration
+
+	// 优雅停机的最长等待时间
+	ShutdownTimeout time.Duration
+	// 停机时就绪检查先返回 503，等待这段时间让负载均衡摘掉实例后再关闭 HTTP 服务，不计入 ShutdownTimeout
+	ShutdownReadinessDelay time.Duration
+
+	// 链路追踪
+	Tracing TracingConfig
+
+	// 日志
+	Log LogConfig
+
+	// 推送事件配置
+	Events EventsConfig
+
+	// 队列消息出现未知字段时拒绝并进入死信队列
+	StrictIngest bool
+
+	// 新订单队列批量写入：每批最多条数（<= 1 表示逐条写入）和凑批的最长等待时间
+	IngestBatchSize int
+	IngestBatchWait time.Duration
+
+	// 消费端去重记录的有效期（0 表示不去重）和过期记录的清理间隔
+	InboxTTL             time.Duration
+	InboxCleanupInterval time.Duration
+
+	// /admin 接口的访问令牌，请求需带 Authorization: Bearer <令牌>；未设置时管理接口不可用
+	AdminToken string
+}
+
+// EventsConfig 推送到 order_event_exchange 的事件格式
+type EventsConfig struct {
+	// 事件信封中的 producer
+	Producer string
+	// 迁移期间继续推送旧格式，下游全部切换到版本化信封后关闭
+	LegacyFormat bool
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	// debug / info / warn / error
+	Level string
+	// text / json
+	Format string
+}
+
+// TracingConfig 链路追踪导出配置
+type TracingConfig struct {
+	// none / stdout / file / otlp-grpc / otlp-http
+	Exporter string
+	// OTLP 地址（host:port）
+	Endpoint string
+	// OTLP 不使用 TLS
+	Insecure bool
+	// file 导出时的文件路径
+	File string
+	// 采样比例，0~1
+	SampleRatio float64
 }
 
 var cfg *Config
 
+// ConsumerWorkers 每个队列消费者的 worker 数
+const ConsumerWorkers = 10
+
+// MaxPrefetch AMQP basic.qos 的 prefetch-count 为 16 位整数，超过会被截断
+const MaxPrefetch = 65535
+
 func LoadConfig() *Config {
 	if cfg != nil {
 		return cfg
@@ -24,9 +116,170 @@ func LoadConfig() *Config {
 		log.Fatal("MYSQL_DSN 或 RABBITMQ_URL 环境变量未设置")
 	}
 
+	// 未指定拓扑文件时使用默认拓扑
+	topology := DefaultTopology()
+	if path := os.Getenv("RABBITMQ_TOPOLOGY_FILE"); path != "" {
+		t, err := LoadTopology(path)
+		if err != nil {
+			log.Fatalf("加载 RabbitMQ 拓扑失败: %v", err)
+		}
+		topology = t
+	}
+	// 交换机的发布编码可以单独用环境变量覆盖，不必提供整份拓扑文件
+	if spec := os.Getenv("RABBITMQ_EXCHANGE_ENCODINGS"); spec != "" {
+		if err := topology.SetEncodings(spec); err != nil {
+			log.Fatalf("解析 RABBITMQ_EXCHANGE_ENCODINGS 失败: %v", err)
+		}
+	}
+
 	cfg = &Config{
 		MySQLDSN:    mysqlDSN,
 		RabbitMQURL: rabbitmqURL,
+		Topology:    topology,
+
+		Compression:          stringEnv("RABBITMQ_COMPRESSION", "none"),
+		CompressionThreshold: intEnv("RABBITMQ_COMPRESSION_THRESHOLD", 64<<10),
+
+		SchedulerInterval:   durationEnv("ORDER_SCHEDULER_INTERVAL", 5*time.Second),
+		OutboxRelayInterval: durationEnv("OUTBOX_RELAY_INTERVAL", 500*time.Millisecond),
+
+		DefaultOrderTTL:     durationEnv("ORDER_DEFAULT_TTL", 0),
+		StrategyOrderTTL:    strategyTTLEnv("ORDER_STRATEGY_TTLS"),
+		ExpirySweepInterval: durationEnv("ORDER_EXPIRY_SWEEP_INTERVAL", 10*time.Second),
+
+		OrderStatusMetricsTTL: durationEnv("METRICS_ORDER_STATUS_TTL", time.Minute),
+
+		InstanceID:          instanceID(),
+		LeaderLeaseTTL:      durationEnv("LEADER_LEASE_TTL", 15*time.Second),
+		LeaderRenewInterval: durationEnv("LEADER_RENEW_INTERVAL", 5*time.Second),
+
+		ShutdownTimeout:        durationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownReadinessDelay: durationEnv("SHUTDOWN_READINESS_DELAY", 5*time.Second),
+
+		Tracing: TracingConfig{
+			Exporter:    stringEnv("TRACING_EXPORTER", "none"),
+			Endpoint:    os.Getenv("TRACING_OTLP_ENDPOINT"),
+			Insecure:    os.Getenv("TRACING_OTLP_INSECURE") == "true",
+			File:        stringEnv("TRACING_FILE", "traces.json"),
+			SampleRatio: floatEnv("TRACING_SAMPLE_RATIO", 1),
+		},
+
+		Log: LogConfig{
+			Level:  stringEnv("LOG_LEVEL", "info"),
+			Format: stringEnv("LOG_FORMAT", "text"),
+		},
+
+		Events: EventsConfig{
+			Producer:     stringEnv("EVENT_PRODUCER", "ordercenter"),
+			LegacyFormat: os.Getenv("EVENT_LEGACY_FORMAT") == "true",
+		},
+
+		StrictIngest: os.Getenv("INGEST_STRICT") == "true",
+
+		IngestBatchSize: intEnv("INGEST_BATCH_SIZE", 0),
+		IngestBatchWait: durationEnv("INGEST_BATCH_WAIT", 50*time.Millisecond),
+
+		InboxTTL:             durationEnv("INBOX_TTL", 24*time.Hour),
+		InboxCleanupInterval: durationEnv("INBOX_CLEANUP_INTERVAL", 10*time.Minute),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+	}
+	// 批量消费时每个 worker 预取一整批
+	if cfg.IngestBatchSize > 1 && cfg.IngestBatchSize*ConsumerWorkers > MaxPrefetch {
+		log.Fatalf("INGEST_BATCH_SIZE=%d 过大：%d 个 worker 共需预取 %d 条，超过上限 %d",
+			cfg.IngestBatchSize, ConsumerWorkers, cfg.IngestBatchSize*ConsumerWorkers, MaxPrefetch)
 	}
 	return cfg
 }
+
+// OrderTTL 返回策略的订单默认有效期
+func (c *Config) OrderTTL(strategyID int64) time.Duration {
+	if ttl, ok := c.StrategyOrderTTL[strategyID]; ok {
+		return ttl
+	}
+	return c.DefaultOrderTTL
+}
+
+// instanceID 优先使用 INSTANCE_ID，否则用 主机名-进程号
+func instanceID() string {
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "ordercenter"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// stringEnv 读取字符串环境变量，未设置时使用默认值
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// floatEnv 读取浮点数环境变量，未设置时使用默认值
+func floatEnv(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("环境变量 %s 格式错误: %v", key, err)
+	}
+	return f
+}
+
+// intEnv 读取整数环境变量，未设置时使用默认值
+func intEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("环境变量 %s 格式错误: %v", key, err)
+	}
+	return n
+}
+
+// durationEnv 读取 time.ParseDuration 格式的环境变量，未设置时使用默认值
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("环境变量 %s 格式错误: %v", key, err)
+	}
+	return d
+}
+
+// strategyTTLEnv 解析按策略的有效期，格式：1001=30m,1002=2h
+func strategyTTLEnv(key string) map[int64]time.Duration {
+	ttls := make(map[int64]time.Duration)
+	v := os.Getenv(key)
+	if v == "" {
+		return ttls
+	}
+	for _, item := range strings.Split(v, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("环境变量 %s 格式错误: %q", key, item)
+		}
+		strategyID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			log.Fatalf("环境变量 %s 策略ID错误: %v", key, err)
+		}
+		ttl, err := time.ParseDuration(parts[1])
+		if err != nil {
+			log.Fatalf("环境变量 %s 有效期错误: %v", key, err)
+		}
+		ttls[strategyID] = ttl
+	}
+	return ttls
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExchangeConfig 交换机定义
+type ExchangeConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // direct / topic / fanout / headers / x-delayed-message
+
+	// Type 为 x-delayed-message 时实际的路由方式（direct / topic / fanout / headers）
+	DelayedType string `json:"delayed_type,omitempty"`
+
+	Args map[string]interface{} `json:"args,omitempty"`
+
+	// 发布到该交换机的消息编码：json（默认）/ msgpack / protobuf
+	Encoding string `json:"encoding,omitempty"`
+	// Encoding 为 protobuf 时发布的消息类型，例如 ordercenter.v1.OrderEvent
+	ProtoMessage string `json:"proto_message,omitempty"`
+}
+
+// 交换机的消息编码
+const (
+	EncodingJSON     = "json"
+	EncodingMsgpack  = "msgpack"
+	EncodingProtobuf = "protobuf"
+)
+
+func validEncoding(encoding string) bool {
+	switch encoding {
+	case "", EncodingJSON, EncodingMsgpack, EncodingProtobuf:
+		return true
+	}
+	return false
+}
+
+// QueueConfig 队列定义
+type QueueConfig struct {
+	Name   string `json:"name"`
+	Quorum bool   `json:"quorum"`
+
+	// 应用在重试耗尽等情况下把消息转发到的死信交换机和 routingKey。
+	// 不作为队列参数声明，broker 端的死信通过 policy 设置，见 DefaultTopology。
+	DeadLetterExchange   string `json:"dead_letter_exchange,omitempty"`
+	DeadLetterRoutingKey string `json:"dead_letter_routing_key,omitempty"`
+	MessageTTLMs         int64  `json:"message_ttl_ms,omitempty"`
+
+	// 其他 x-* 参数，原样传给 broker
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// BindingConfig 队列与交换机的绑定
+type BindingConfig struct {
+	Queue      string `json:"queue"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+
+	// headers 交换机的匹配条件，例如 {"x-match": "all", "chain_index": 56}
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Topology RabbitMQ 拓扑：启动和重连时都按这一份声明
+type Topology struct {
+	Exchanges []ExchangeConfig `json:"exchanges"`
+	Queues    []QueueConfig    `json:"queues"`
+	Bindings  []BindingConfig  `json:"bindings"`
+}
+
+// DefaultTopology 订单中心默认拓扑
+//
+// 订单事件推送到 topic 类型的 order_event_exchange，新订单的 routingKey 形如
+// order.<chain>.<event_type>，生命周期事件再追加一段动作（如 order.56.buy.expired）。
+// order_push_queue 绑定 order.*.*，即只接收新订单。
+// 原有的 direct 类型 order_push_exchange 及其 new_order 绑定保持不变，
+// 已部署的 broker 可以直接升级，旧版本实例在滚动升级期间推送的消息也仍能进入 order_push_queue。
+//
+// 应用处理失败（重试耗尽、消息格式错误、panic）的消息转发到 order_dlx_exchange，
+// 进入 <队列名>.dlq。队列参数不可原地修改，broker 端的死信不写进队列参数，需要时用 policy 设置：
+//
+//	rabbitmqctl set_policy ordercenter-dlx '^(multi_strategy_on_one_token_queue|withdraw_order_queue)$' \
+//	  '{"dead-letter-exchange":"order_dlx_exchange"}' --apply-to queues
+func DefaultTopology() *Topology {
+	return &Topology{
+		Exchanges: []ExchangeConfig{
+			{Name: "basic_info_exchange", Type: "direct"},
+			{Name: "order_push_exchange", Type: "direct"},
+			// 按 protobuf 编码推送时使用事件信封的消息类型
+			{Name: OrderEventExchange, Type: "topic", ProtoMessage: "ordercenter.v1.OrderEvent"},
+			{Name: DeadLetterExchange, Type: "direct"},
+		},
+		Queues: []QueueConfig{
+			withDeadLetter(QueueConfig{Name: "multi_strategy_on_one_token_queue", Quorum: true}),
+			withDeadLetter(QueueConfig{Name: "withdraw_order_queue", Quorum: true}),
+			{Name: "order_push_queue", Quorum: true},
+			{Name: DeadLetterQueue("multi_strategy_on_one_token_queue"), Quorum: true},
+			{Name: DeadLetterQueue("withdraw_order_queue"), Quorum: true},
+		},
+		Bindings: []BindingConfig{
+			{Queue: "multi_strategy_on_one_token_queue", Exchange: "basic_info_exchange", RoutingKey: "basic_info"},
+			{Queue: "order_push_queue", Exchange: "order_push_exchange", RoutingKey: "new_order"},
+			{Queue: "order_push_queue", Exchange: OrderEventExchange, RoutingKey: "order.*.*"},
+			{Queue: DeadLetterQueue("multi_strategy_on_one_token_queue"), Exchange: DeadLetterExchange, RoutingKey: "multi_strategy_on_one_token_queue"},
+			{Queue: DeadLetterQueue("withdraw_order_queue"), Exchange: DeadLetterExchange, RoutingKey: "withdraw_order_queue"},
+		},
+	}
+}
+
+// OrderEventExchange 订单事件的推送交换机
+const OrderEventExchange = "order_event_exchange"
+
+// DeadLetterExchange 死信交换机，routingKey 为原队列名
+const DeadLetterExchange = "order_dlx_exchange"
+
+// DeadLetterQueue 队列对应的死信队列名
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+func withDeadLetter(q QueueConfig) QueueConfig {
+	q.DeadLetterExchange = DeadLetterExchange
+	q.DeadLetterRoutingKey = q.Name
+	return q
+}
+
+// Queue 按名称查找队列定义
+func (t *Topology) Queue(name string) (QueueConfig, bool) {
+	for _, q := range t.Queues {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return QueueConfig{}, false
+}
+
+// LoadTopology 从 JSON 文件读取拓扑
+func LoadTopology(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取拓扑文件失败: %w", err)
+	}
+	var t Topology
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("解析拓扑文件失败: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SetEncodings 覆盖交换机的发布编码，格式：order_event_exchange=msgpack,basic_info_exchange=json。
+// 改为 protobuf 时使用拓扑中该交换机的 proto_message。
+func (t *Topology) SetEncodings(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("交换机编码格式错误: %q", item)
+		}
+		found := false
+		for i := range t.Exchanges {
+			if t.Exchanges[i].Name == parts[0] {
+				t.Exchanges[i].Encoding = parts[1]
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("交换机编码引用了未定义的交换机: %s", parts[0])
+		}
+	}
+	return t.Validate()
+}
+
+// Validate 检查名称重复以及绑定是否引用了未定义的交换机/队列
+func (t *Topology) Validate() error {
+	exchanges := make(map[string]bool)
+	for _, ex := range t.Exchanges {
+		if ex.Name == "" {
+			return fmt.Errorf("交换机名称不能为空")
+		}
+		if exchanges[ex.Name] {
+			return fmt.Errorf("交换机 %s 重复定义", ex.Name)
+		}
+		if !validEncoding(ex.Encoding) {
+			return fmt.Errorf("交换机 %s 的编码 %q 不支持", ex.Name, ex.Encoding)
+		}
+		if ex.Encoding == EncodingProtobuf && ex.ProtoMessage == "" {
+			return fmt.Errorf("交换机 %s 使用 protobuf 编码时必须指定 proto_message", ex.Name)
+		}
+		exchanges[ex.Name] = true
+	}
+
+	queues := make(map[string]bool)
+	for _, q := range t.Queues {
+		if q.Name == "" {
+			return fmt.Errorf("队列名称不能为空")
+		}
+		if queues[q.Name] {
+			return fmt.Errorf("队列 %s 重复定义", q.Name)
+		}
+		queues[q.Name] = true
+	}
+
+	for _, b := range t.Bindings {
+		if !queues[b.Queue] {
+			return fmt.Errorf("绑定引用了未定义的队列: %s", b.Queue)
+		}
+		if !exchanges[b.Exchange] {
+			return fmt.Errorf("绑定引用了未定义的交换机: %s", b.Exchange)
+		}
+	}
+	return nil
+}
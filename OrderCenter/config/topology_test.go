@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultTopologyValid(t *testing.T) {
+	if err := DefaultTopology().Validate(); err != nil {
+		t.Fatalf("默认拓扑校验失败: %v", err)
+	}
+}
+
+func TestTopologyValidate(t *testing.T) {
+	exchange := ExchangeConfig{Name: "ex", Type: "topic"}
+	queue := QueueConfig{Name: "q"}
+	tests := []struct {
+		name     string
+		topology Topology
+		wantErr  bool
+	}{
+		{"空拓扑", Topology{}, false},
+		{"正常", Topology{
+			Exchanges: []ExchangeConfig{exchange},
+			Queues:    []QueueConfig{queue},
+			Bindings:  []BindingConfig{{Queue: "q", Exchange: "ex", RoutingKey: "order.*.*"}},
+		}, false},
+		{"交换机名称为空", Topology{Exchanges: []ExchangeConfig{{Type: "direct"}}}, true},
+		{"交换机重复", Topology{Exchanges: []ExchangeConfig{exchange, exchange}}, true},
+		{"msgpack 编码", Topology{Exchanges: []ExchangeConfig{{Name: "ex", Encoding: EncodingMsgpack}}}, false},
+		{"protobuf 编码", Topology{Exchanges: []ExchangeConfig{{Name: "ex", Encoding: EncodingProtobuf, ProtoMessage: "ordercenter.v1.OrderEvent"}}}, false},
+		{"protobuf 未指定消息类型", Topology{Exchanges: []ExchangeConfig{{Name: "ex", Encoding: EncodingProtobuf}}}, true},
+		{"不支持的编码", Topology{Exchanges: []ExchangeConfig{{Name: "ex", Encoding: "avro"}}}, true},
+		{"队列名称为空", Topology{Queues: []QueueConfig{{}}}, true},
+		{"队列重复", Topology{Queues: []QueueConfig{queue, queue}}, true},
+		{"绑定未定义的队列", Topology{
+			Exchanges: []ExchangeConfig{exchange},
+			Bindings:  []BindingConfig{{Queue: "missing", Exchange: "ex"}},
+		}, true},
+		{"绑定未定义的交换机", Topology{
+			Queues:   []QueueConfig{queue},
+			Bindings: []BindingConfig{{Queue: "q", Exchange: "missing"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.topology.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTopology(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"正常", `{"exchanges":[{"name":"ex","type":"topic"}],"queues":[{"name":"q","quorum":true}],"bindings":[{"queue":"q","exchange":"ex","routing_key":"k"}]}`, false},
+		{"格式错误", `{"exchanges":`, true},
+		{"校验失败", `{"bindings":[{"queue":"q","exchange":"ex"}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "topology.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			topology, err := LoadTopology(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTopology err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if q, ok := topology.Queue("q"); !ok || !q.Quorum {
+					t.Fatalf("队列 q = %+v, %v", q, ok)
+				}
+			}
+		})
+	}
+	if _, err := LoadTopology(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestSetEncodings(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"msgpack", "order_event_exchange=msgpack", EncodingMsgpack, false},
+		{"多个交换机", "order_event_exchange=json, basic_info_exchange=msgpack", EncodingJSON, false},
+		{"格式错误", "order_event_exchange", "", true},
+		{"未定义的交换机", "missing_exchange=json", "", true},
+		// 默认拓扑已指定推送的消息类型
+		{"protobuf", "order_event_exchange=protobuf", EncodingProtobuf, false},
+		{"未指定消息类型", "basic_info_exchange=protobuf", "", true},
+		{"不支持的编码", "order_event_exchange=avro", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topology := DefaultTopology()
+			err := topology.SetEncodings(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetEncodings err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, ex := range topology.Exchanges {
+				if ex.Name == "order_event_exchange" && ex.Encoding != tt.want {
+					t.Fatalf("order_event_exchange encoding = %q, want %q", ex.Encoding, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// 已部署的 order_push_exchange 类型和绑定不能变，否则重新声明时 broker 返回 PRECONDITION_FAILED
+func TestDefaultTopologyKeepsPushExchange(t *testing.T) {
+	topology := DefaultTopology()
+	for _, ex := range topology.Exchanges {
+		if ex.Name == "order_push_exchange" && ex.Type != "direct" {
+			t.Fatalf("order_push_exchange type = %s, want direct", ex.Type)
+		}
+	}
+	want := map[string]bool{"order_push_exchange/new_order": false, OrderEventExchange + "/order.*.*": false}
+	for _, b := range topology.Bindings {
+		if b.Queue != "order_push_queue" {
+			continue
+		}
+		key := b.Exchange + "/" + b.RoutingKey
+		if _, ok := want[key]; !ok {
+			t.Fatalf("order_push_queue 多出绑定 %s", key)
+		}
+		want[key] = true
+	}
+	for key, found := range want {
+		if !found {
+			t.Fatalf("order_push_queue 缺少绑定 %s", key)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes 管理接口需要带上 token：Authorization: Bearer <token>。
+// token 为空时管理接口全部返回 403。
+func RegisterAdminRoutes(r *gin.Engine, srv *service.OrderService, token string) {
+	group := r.Group("/admin", adminAuth(token))
+
+	// 按策略、用户、代币或链撤掉全部未过期订单，多个条件之间为 AND
+	group.POST("/orders/withdraw-all", func(c *gin.Context) {
+		var scope model.WithdrawScope
+		if err := c.ShouldBindJSON(&scope); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := srv.WithdrawAll(c.Request.Context(), scope)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrInvalidBatch) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error(), "result": res})
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	})
+}
+
+// adminAuth 校验管理接口的 token，使用常量时间比较
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "管理接口未启用"})
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "管理接口认证失败"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+	"trade-solution/ordercenter/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterHealthRoutes(r *gin.Engine, health *service.HealthChecker) {
+	// 存活检查：进程能响应即可，不检查依赖
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// 就绪检查：依赖不可用或正在停机时返回 503
+	r.GET("/readyz", func(c *gin.Context) {
+		report := health.Ready(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var mysqlErr error
+	health := service.NewHealthChecker(time.Second)
+	health.Register("mysql", func(context.Context) (interface{}, error) { return nil, mysqlErr })
+	r := gin.New()
+	RegisterHealthRoutes(r, health)
+
+	get := func(path string) (*httptest.ResponseRecorder, service.ReadinessReport) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report service.ReadinessReport
+		if path == "/readyz" {
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, report
+	}
+
+	if w, report := get("/readyz"); w.Code != http.StatusOK || !report.Ready {
+		t.Fatalf("依赖可用: %d %s", w.Code, w.Body)
+	}
+
+	// 依赖不可用时就绪检查失败，存活检查不受影响
+	mysqlErr = errors.New("连接被拒绝")
+	if w, report := get("/readyz"); w.Code != http.StatusServiceUnavailable || report.Checks[0].Error != mysqlErr.Error() {
+		t.Fatalf("依赖不可用: %d %s", w.Code, w.Body)
+	}
+	if w, _ := get("/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("healthz = %d", w.Code)
+	}
+
+	mysqlErr = nil
+	health.SetShuttingDown()
+	if w, report := get("/readyz"); w.Code != http.StatusServiceUnavailable || !report.ShuttingDown {
+		t.Fatalf("停机中: %d %s", w.Code, w.Body)
+	}
+}
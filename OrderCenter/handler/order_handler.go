@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"trade-solution/ordercenter/model"
 	"trade-solution/ordercenter/service"
 
@@ -17,16 +19,80 @@ func RegisterOrderRoutes(r *gin.Engine, srv *service.OrderService) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := srv.CreateOrder(&order); err != nil {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		if err := srv.CreateAndPublishOrder(c.Request.Context(), &order); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrOrderExists) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "订单创建成功"})
 	})
 
+	// 批量创建，mode 为 atomic（默认，任一条失败整批不写入）或 best_effort
+	group.POST("/batch", func(c *gin.Context) {
+		var req batchOrdersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := srv.CreateOrders(c.Request.Context(), req.Orders, batchMode(req.Mode))
+		writeBatchResult(c, res, err)
+	})
+
+	// 批量更新，每条按 order_id 更新非零字段，已过期或已撤单的订单不能更新
+	group.POST("/batch-update", func(c *gin.Context) {
+		var req batchOrdersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := srv.UpdateOrders(c.Request.Context(), req.Orders, batchMode(req.Mode))
+		writeBatchResult(c, res, err)
+	})
+
+	// 批量撤单，订单置为 withdrawn 并保留记录
+	group.POST("/batch-withdraw", func(c *gin.Context) {
+		var req batchWithdrawRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := srv.WithdrawOrders(c.Request.Context(), req.OrderIDs, batchMode(req.Mode))
+		writeBatchResult(c, res, err)
+	})
+
+	// 统计：group_by 多个字段用逗号分隔，interval 和 time_field 控制时间分桶，其余参数同列表过滤
+	group.GET("/stats", func(c *gin.Context) {
+		var filter model.OrderFilter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q := model.OrderStatsQuery{
+			Filter:    filter,
+			Interval:  c.Query("interval"),
+			TimeField: c.Query("time_field"),
+		}
+		if groupBy := c.Query("group_by"); groupBy != "" {
+			q.GroupBy = strings.Split(groupBy, ",")
+		}
+		stats, err := srv.GetOrderStats(c.Request.Context(), q)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrInvalidQuery) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"stats": stats})
+	})
+
 	group.GET("/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		order, err := srv.GetOrderByID(id)
+		order, err := srv.GetOrderByID(c.Request.Context(), id)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
@@ -41,8 +107,8 @@ func RegisterOrderRoutes(r *gin.Engine, srv *service.OrderService) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := srv.UpdateOrder(id, &updated); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := srv.UpdateOrder(c.Request.Context(), id, &updated); err != nil {
+			c.JSON(mutationStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "订单更新成功"})
@@ -50,15 +116,20 @@ func RegisterOrderRoutes(r *gin.Engine, srv *service.OrderService) {
 
 	group.DELETE("/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		if err := srv.DeleteOrder(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := srv.DeleteOrder(c.Request.Context(), id); err != nil {
+			c.JSON(mutationStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "订单删除成功"})
+		c.JSON(http.StatusOK, gin.H{"message": "撤单成功"})
 	})
 
 	group.GET("", func(c *gin.Context) {
-		orders, err := srv.GetAllOrders()
+		var filter model.OrderFilter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		orders, err := srv.GetAllOrders(c.Request.Context(), filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -66,3 +137,50 @@ func RegisterOrderRoutes(r *gin.Engine, srv *service.OrderService) {
 		c.JSON(http.StatusOK, orders)
 	})
 }
+
+type batchOrdersRequest struct {
+	Mode   string            `json:"mode"`
+	Orders []model.OrderData `json:"orders" binding:"required"`
+}
+
+type batchWithdrawRequest struct {
+	Mode     string   `json:"mode"`
+	OrderIDs []string `json:"order_ids" binding:"required"`
+}
+
+func batchMode(mode string) string {
+	if mode == "" {
+		return service.BatchModeAtomic
+	}
+	return mode
+}
+
+// writeBatchResult 整批回滚时返回 409，其余情况返回 200，由 results 区分每条结果
+func writeBatchResult(c *gin.Context, res *service.BatchResult, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidBatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if res.RolledBack {
+		c.JSON(http.StatusConflict, res)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// mutationStatus 订单不存在返回 404，已处于终态返回 409，更新内容无效返回 400，其余错误返回 500
+func mutationStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrOrderClosed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidUpdate):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+	"trade-solution/ordercenter/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterSchemaRoutes 对外提供推送事件的 JSON Schema，供下游校验和生成代码
+func RegisterSchemaRoutes(r *gin.Engine) {
+	r.GET("/schemas/order-event/v1", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/schema+json", service.OrderEventSchema)
+	})
+}
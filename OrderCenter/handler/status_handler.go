@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"net/http"
+	"trade-solution/ordercenter/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterStatusRoutes(r *gin.Engine, elector *service.LeaderElector) {
+	group := r.Group("/status")
+
+	group.GET("/leader", func(c *gin.Context) {
+		c.JSON(http.StatusOK, elector.Status())
+	})
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	orderIDKey
+)
+
+// MaxRequestIDLen 请求 ID 的最大字符数，与 event_outbox.correlation_id 列一致
+const MaxRequestIDLen = 64
+
+// WithRequestID 把请求 ID 放入 ctx，超过 MaxRequestIDLen 的部分截掉。
+// 同一个 ID 在 HTTP 请求（X-Request-ID）和 AMQP 消息（correlation_id）之间传递，
+// 来自调用方的 ID 长度不受控制，截断后写入发件箱不会因超长导致订单变更回滚。
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if runes := []rune(id); len(runes) > MaxRequestIDLen {
+		id = string(runes[:MaxRequestIDLen])
+	}
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithOrderID 把订单 ID 放入 ctx，之后的日志都会带上 order_id
+func WithOrderID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, orderIDKey, id)
+}
+
+func OrderID(ctx context.Context) string {
+	id, _ := ctx.Value(orderIDKey).(string)
+	return id
+}
+
+// NewRequestID 生成随机的请求 ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWithRequestID(t *testing.T) {
+	long := strings.Repeat("a", MaxRequestIDLen+10)
+	wide := strings.Repeat("请", MaxRequestIDLen+1)
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"空", "", ""},
+		{"正常", "req-1", "req-1"},
+		{"恰好上限", long[:MaxRequestIDLen], long[:MaxRequestIDLen]},
+		{"超长", long, long[:MaxRequestIDLen]},
+		// 按字符截断，不切开多字节字符
+		{"多字节超长", wide, string([]rune(wide)[:MaxRequestIDLen])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequestID(WithRequestID(context.Background(), tt.id)); got != tt.want {
+				t.Fatalf("RequestID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGinMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	long := strings.Repeat("x", 300)
+	tests := []struct {
+		name   string
+		header string
+		check  func(id string) bool
+	}{
+		{"沿用调用方的 ID", "req-1", func(id string) bool { return id == "req-1" }},
+		{"生成新 ID", "", func(id string) bool { return len(id) == 32 }},
+		{"超长 ID 截断", long, func(id string) bool { return id == long[:MaxRequestIDLen] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inCtx string
+			r := gin.New()
+			r.Use(GinMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))))
+			r.GET("/", func(c *gin.Context) {
+				inCtx = RequestID(c.Request.Context())
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			echoed := w.Header().Get(HeaderRequestID)
+			if !tt.check(inCtx) || echoed != inCtx {
+				t.Fatalf("ctx 中的请求 ID = %q, 响应头 = %q", inCtx, echoed)
+			}
+		})
+	}
+}
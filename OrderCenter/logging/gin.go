@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID 请求 ID 的 HTTP 头
+const HeaderRequestID = "X-Request-ID"
+
+// GinMiddleware 沿用调用方传入的 X-Request-ID（没有时生成，超长时截断），写回响应头并放入请求 ctx，
+// 请求结束后记录一条访问日志
+func GinMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if id == "" {
+			id = NewRequestID()
+		}
+		ctx := WithRequestID(c.Request.Context(), id)
+		c.Header(HeaderRequestID, RequestID(ctx))
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx, level, "HTTP 请求",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"trade-solution/ordercenter/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 日志字段名
+const (
+	KeyRequestID = "request_id"
+	KeyOrderID   = "order_id"
+	KeyTraceID   = "trace_id"
+	KeyError     = "error"
+)
+
+// sensitiveKeys 输出前需要脱敏的字段
+var sensitiveKeys = map[string]bool{
+	"bsc_public_key": true,
+	"sol_public_key": true,
+	"bscPublicKey":   true,
+	"solPublicKey":   true,
+	"public_key":     true,
+}
+
+// New 按配置创建日志，format 为 json 时输出 JSON，否则输出 key=value 文本。
+// 日志会自动带上 ctx 中的 request_id、order_id 和 trace_id，公钥类字段只保留首尾。
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(cfg.Level),
+		ReplaceAttr: redactAttr,
+	}
+	var h slog.Handler
+	if strings.EqualFold(cfg.Format, "json") {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// Err 统一错误字段
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// Redact 脱敏，只保留首尾各 4 位
+func Redact(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + "****" + s[len(s)-4:]
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[a.Key] && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, Redact(a.Value.String()))
+	}
+	return a
+}
+
+// contextHandler 从 ctx 中取出关联 ID 追加到每条日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if id := OrderID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyOrderID, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -2,18 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 	"trade-solution/ordercenter/config"
 	"trade-solution/ordercenter/handler"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/metrics"
 	"trade-solution/ordercenter/repository"
 	"trade-solution/ordercenter/service"
+	"trade-solution/ordercenter/tracing"
 	"trade-solution/ordercenter/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
@@ -24,93 +35,162 @@ func main() {
 	}
 	cfg := config.LoadConfig()
 
+	// 结构化日志，标准库 log 的输出也会转到这里
+	logger := logging.New(os.Stdout, cfg.Log)
+	slog.SetDefault(logger)
+	fatal := func(msg string, err error) {
+		logger.Error(msg, logging.Err(err))
+		os.Exit(1)
+	}
+
+	// 收到 SIGINT / SIGTERM 后开始优雅停机
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// RabbitMQ 和数据库连接要等消费者和发布都结束后才关闭，单独使用一个 context
+	connCtx, closeConn := context.WithCancel(context.Background())
+	defer closeConn()
+
+	// 链路追踪，停机时最后关闭以导出剩余的 span
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing)
+	if err != nil {
+		fatal("初始化链路追踪失败", err)
+	}
+
 	// 初始化数据库
 	db, err := service.InitDB(cfg.MySQLDSN)
 	if err != nil {
-		log.Fatalf("初始化数据库失败: %v", err)
+		fatal("初始化数据库失败", err)
 	}
+	dbProvider := repository.NewDBProvider(db)
 
 	// 启动监控和自动重连
-	go service.MonitorAndReconnectDB(cfg.MySQLDSN, &db, 30*time.Second)
+	go service.MonitorAndReconnectDB(connCtx, cfg.MySQLDSN, dbProvider, 30*time.Second, logger)
 
-	// 初始化RabbitMQ
-	rabbitMQ, err := utils.InitRabbitMQ(cfg.RabbitMQURL)
+	// 初始化RabbitMQ，连接和每次重连时都会按配置声明拓扑
+	rabbitMQ, err := utils.InitRabbitMQ(cfg.RabbitMQURL, cfg.Topology, logger)
 	if err != nil {
-		log.Fatalf("初始化RabbitMQ失败: %v", err)
+		fatal("初始化RabbitMQ失败", err)
 	}
-
-	// 声明交换机
-	err = rabbitMQ.DeclareExchange(
-		"basic_info_exchange", // 交换机名称
-	)
-	if err != nil {
-		log.Fatalf("声明交换机失败: %v", err)
+	if err := rabbitMQ.SetCompression(cfg.Compression, cfg.CompressionThreshold); err != nil {
+		fatal("RabbitMQ 压缩配置错误", err)
 	}
-	_, err = rabbitMQ.DeclareQueueQuorum("multi_strategy_on_one_token_queue")
-	if err != nil {
-		log.Fatalf("声明队列失败: %v", err)
+	if err := rabbitMQ.VerifyTopology(cfg.Topology); err != nil {
+		fatal("校验 RabbitMQ 拓扑失败", err)
 	}
 
-	_, err = rabbitMQ.DeclareQueueQuorum("withdraw_order_queue")
-	if err != nil {
-		log.Fatalf("声明队列失败: %v", err)
+	// 依赖注入
+	orderRepo := repository.NewOrderRepository(dbProvider)
+	events := service.NewEventPublisher(rabbitMQ, cfg.Events.Producer, cfg.Events.LegacyFormat)
+	if err := events.Validate(); err != nil {
+		fatal("事件推送配置错误", err)
 	}
+	orderSrv := service.NewOrderService(orderRepo, service.WithOrderTTL(cfg.OrderTTL), service.WithEventPublisher(events),
+		service.WithStrictIngest(cfg.StrictIngest), service.WithInboxTTL(cfg.InboxTTL), service.WithLogger(logger))
 
-	// 绑定队列
-	routingKey := "basic_info"
-	err = rabbitMQ.BindQueue("multi_strategy_on_one_token_queue", "basic_info_exchange", routingKey)
+	// 启动 RabbitMQ 断线重连监听
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		rabbitMQ.Run(connCtx)
+	}()
+
+	// 启动消费者，断线后自动重新订阅
+	var consumers sync.WaitGroup
+	ingestBatch := service.IngestBatch{Size: cfg.IngestBatchSize, Wait: cfg.IngestBatchWait}
+	orderConsumers, err := service.StartOrderConsumers(ctx, &consumers, rabbitMQ, orderSrv, ingestBatch)
 	if err != nil {
-		log.Fatalf("绑定队列失败: %v", err)
+		fatal("启动消费者失败", err)
 	}
 
-	if err := rabbitMQ.DeclareExchange("order_push_exchange"); err != nil {
-		log.Fatalf("声明交换机失败: %v", err)
-	}
-	// （可选）绑定队列
-	if _, err := rabbitMQ.DeclareQueueQuorum("order_push_queue"); err != nil {
-		log.Fatalf("声明队列失败: %v", err)
-	}
-	if err := rabbitMQ.BindQueue("order_push_queue", "order_push_exchange", "new_order"); err != nil {
-		log.Fatalf("绑定队列失败: %v", err)
+	// 就绪检查：数据库、RabbitMQ 以及每个消费者
+	health := service.NewHealthChecker(3 * time.Second)
+	health.Register("mysql", service.DBHealthCheck(dbProvider))
+	health.Register("rabbitmq", service.RabbitMQHealthCheck(rabbitMQ))
+	for _, c := range orderConsumers {
+		health.Register("consumer:"+c.Queue(), service.ConsumerHealthCheck(c))
 	}
 
-	// 依赖注入
-	orderRepo := repository.NewOrderRepository(db)
-	orderSrv := service.NewOrderService(orderRepo)
+	// 单例后台任务只在主节点上运行
+	elector := service.NewLeaderElector(repository.NewLeaseRepository(dbProvider), "ordercenter", cfg.InstanceID, cfg.LeaderLeaseTTL, cfg.LeaderRenewInterval, logger)
 
-	// 启动 RabbitMQ 断线重连监听
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go utils.MonitorAndReconnect(ctx, cfg.RabbitMQURL, func(rmq *utils.RabbitMQ) {
-		//// 重连时需要重新声明队列和绑定
-		rabbitMQ = rmq
-		// 重新声明队列（可选）
-		rmq.DeclareExchange("basic_info_exchange")
-		rmq.DeclareQueueQuorum("multi_strategy_on_one_token_queue")
-		rmq.DeclareQueueQuorum("order_queue")
-		rmq.DeclareQueueQuorum("order_active_queue")
-
-		rmq.DeclareExchange("order_push_exchange")
-		rmq.DeclareQueueQuorum("order_push_queue")
-		rmq.BindQueue("order_push_queue", "order_push_exchange", "new_order")
-
-		rmq.DeclareQueueQuorum("withdraw_order_queue")
-
-		rmq.BindQueue("multi_strategy_on_one_token_queue", "basic_info_exchange", "basic_info")
-		// 启动消费者
-		go service.StartMultiStrategyConsumer("multi_strategy_on_one_token_queue", rabbitMQ, 10, 10, db)
-		//go service.StartMultiStrategyConsumer("invalid_order_queue", rabbitMQ, 10, 10, db)
-
-		go service.StartWithdrawConsumer("withdraw_order_queue", rabbitMQ, 10, 10, db)
-	})
+	// 调度订单到期推送
+	scheduler := service.NewOrderScheduler(orderRepo, events, cfg.SchedulerInterval, logger)
+	elector.Register("order_scheduler", scheduler.Run)
+
+	// 过期订单清理
+	sweeper := service.NewOrderExpirySweeper(orderRepo, events, cfg.ExpirySweepInterval, logger)
+	elector.Register("order_expiry_sweeper", sweeper.Run)
+
+	// 推送发件箱中的订单事件
+	relay := service.NewOutboxRelay(orderRepo, rabbitMQ, cfg.OutboxRelayInterval, logger)
+	elector.Register("outbox_relay", relay.Run)
+
+	// 过期去重记录清理
+	inboxCleaner := service.NewInboxCleaner(orderRepo, cfg.InboxCleanupInterval, logger)
+	elector.Register("inbox_cleaner", inboxCleaner.Run)
+
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(ctx)
+	}()
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
-	r.Use(gin.Recovery(), cors.Default(), gzip.Gzip(gzip.DefaultCompression))
+	r.Use(gin.Recovery(), otelgin.Middleware(tracing.ServiceName), logging.GinMiddleware(logger), metrics.GinMiddleware(), cors.Default(), gzip.Gzip(gzip.DefaultCompression,
+		// /metrics 由 promhttp 自行压缩
+		gzip.WithExcludedPaths([]string{"/metrics"})))
+
+	// 监控指标
+	metrics.RegisterConnectionState(dbProvider.Healthy, rabbitMQ.Connected)
+	metrics.RegisterOrderStatusCollector(orderRepo.CountByStatus, cfg.OrderStatusMetricsTTL)
 
 	// 注册路由
 	handler.RegisterOrderRoutes(r, orderSrv)
-
-	log.Println("🚀 服务器启动在端口 :8016")
-	r.Run(":8016")
+	handler.RegisterStatusRoutes(r, elector)
+	handler.RegisterMetricsRoutes(r)
+	handler.RegisterHealthRoutes(r, health)
+	if cfg.AdminToken == "" {
+		logger.Warn("未设置 ADMIN_TOKEN，管理接口已禁用")
+	}
+	handler.RegisterAdminRoutes(r, orderSrv, cfg.AdminToken)
+	handler.RegisterSchemaRoutes(r)
+
+	server := &http.Server{Addr: ":8016", Handler: r}
+	go func() {
+		logger.Info("服务器已启动", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("HTTP 服务启动失败", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	logger.Info("收到停机信号，开始优雅停机", "timeout", cfg.ShutdownTimeout, "readiness_delay", cfg.ShutdownReadinessDelay)
+
+	// 就绪检查先返回 503，等探针发现并摘掉实例后再按 shutdownHooks.steps 的顺序停机
+	health.SetShuttingDown()
+	time.Sleep(cfg.ShutdownReadinessDelay)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+
+	runShutdown(shutdownCtx, logger, shutdownHooks{
+		stopHTTP:      server.Shutdown,
+		consumersDone: waitGroupDone(&consumers),
+		jobsDone:      electorDone,
+		waitPublishes: rabbitMQ.WaitPublishes,
+		closeConn:     closeConn,
+		monitorDone:   monitorDone,
+		closeDB: func() error {
+			sqlDB, err := dbProvider.Get().DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		},
+		shutdownTracing: shutdownTracing,
+	}.steps())
+	logger.Info("服务已停止")
 }
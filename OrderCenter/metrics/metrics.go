@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "ordercenter"
+
+// 消费结果
+const (
+	ResultProcessed    = "processed"
+	ResultFailed       = "failed"
+	ResultDuplicate    = "duplicate"
+	ResultDeadLettered = "dead_lettered"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	HTTPLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// result: processed / failed / duplicate / dead_lettered
+	ConsumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_messages_total",
+		Help:      "消费消息数，按处理结果区分",
+	}, []string{"queue", "result"})
+
+	ConsumerLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "consumer_processing_duration_seconds",
+		Help:      "单条消息处理耗时",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"queue"})
+
+	ConsumerBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "consumer_batch_size",
+		Help:      "批量消费时每批的消息数",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{"queue"})
+
+	PublishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_failures_total",
+		Help:      "发布失败次数",
+	}, []string{"exchange"})
+
+	// 最早一条待推送事件的等待时间，持续增长说明推送卡住
+	OutboxOldestAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_oldest_event_age_seconds",
+		Help:      "发件箱中最早一条待推送事件的等待时间",
+	})
+
+	DBReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reconnects_total",
+		Help:      "数据库重连成功次数",
+	})
+
+	AMQPReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_reconnects_total",
+		Help:      "RabbitMQ 重连成功次数",
+	})
+)
+
+// GinMiddleware 按路由模板统计请求数和耗时，未匹配路由的请求统一记为 unmatched
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		HTTPLatency.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterConnectionState 注册数据库和 RabbitMQ 的当前连接状态（1 已连接，0 断开）
+func RegisterConnectionState(dbHealthy, amqpConnected func() bool) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_connected",
+		Help:      "数据库连接状态",
+	}, func() float64 { return boolToFloat(dbHealthy()) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "amqp_connected",
+		Help:      "RabbitMQ 连接状态",
+	}, func() float64 { return boolToFloat(amqpConnected()) })
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// orderStatusCollector 抓取时查询各状态的订单数。
+// 统计是全表 GROUP BY，结果缓存 ttl，多个抓取方或频繁抓取时不会反复查库。
+type orderStatusCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) (map[string]int64, error)
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	counts    map[string]int64
+	fetchedAt time.Time
+}
+
+// RegisterOrderStatusCollector 注册按状态统计的订单数，ttl 内的抓取复用上次的结果
+func RegisterOrderStatusCollector(count func(ctx context.Context) (map[string]int64, error), ttl time.Duration) {
+	prometheus.MustRegister(&orderStatusCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "orders"),
+			"订单数，按状态区分",
+			[]string{"status"}, nil,
+		),
+		count: count,
+		ttl:   ttl,
+	})
+}
+
+func (c *orderStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *orderStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	for status, n := range c.cachedCounts() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
+
+// cachedCounts 缓存过期时重新查询；查询失败时沿用上次的结果
+func (c *orderStatusCollector) cachedCounts() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.counts
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "统计订单状态失败", "error", err)
+		return c.counts
+	}
+	c.counts, c.fetchedAt = counts, time.Now()
+	return counts
+}
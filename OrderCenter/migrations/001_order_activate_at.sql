@@ -0,0 +1,4 @@
+-- 订单延迟生效：activate_at 为空表示立即生效
+ALTER TABLE order_data
+    ADD COLUMN activate_at DATETIME(3) NULL AFTER event_type,
+    ADD INDEX idx_order_data_status_activate_at (status, activate_at);
@@ -0,0 +1,17 @@
+-- 订单过期：expires_at 为空表示不过期
+ALTER TABLE order_data
+    ADD COLUMN expires_at DATETIME(3) NULL AFTER activate_at,
+    ADD INDEX idx_order_data_expires_at (expires_at);
+
+-- 订单状态变更历史
+CREATE TABLE IF NOT EXISTS order_history (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    order_id    VARCHAR(191)    NOT NULL,
+    action      VARCHAR(32)     NOT NULL,
+    from_status VARCHAR(64)     NOT NULL DEFAULT '',
+    to_status   VARCHAR(64)     NOT NULL DEFAULT '',
+    snapshot    JSON            NULL,
+    created_at  DATETIME(3)     NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_order_history_order_id (order_id)
+);
@@ -0,0 +1,9 @@
+-- 单例后台任务的领导权租约
+CREATE TABLE IF NOT EXISTS leader_lease (
+    name          VARCHAR(64)  NOT NULL,
+    holder        VARCHAR(191) NOT NULL DEFAULT '',
+    fencing_token BIGINT       NOT NULL DEFAULT 0,
+    expires_at    DATETIME(3)  NOT NULL,
+    updated_at    DATETIME(3)  NOT NULL,
+    PRIMARY KEY (name)
+);
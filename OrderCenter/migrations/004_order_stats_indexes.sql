@@ -0,0 +1,5 @@
+-- 订单列表过滤和统计常用的条件（status 已由 idx_order_data_status_activate_at 覆盖）
+ALTER TABLE order_data
+    ADD INDEX idx_order_data_strategy_token (strategy_id, token_address),
+    ADD INDEX idx_order_data_user_id (user_id),
+    ADD INDEX idx_order_data_created_at (created_at);
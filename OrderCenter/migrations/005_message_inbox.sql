@@ -0,0 +1,9 @@
+-- 消费端去重：已处理的队列消息，message_id 为消息的 message_id 或消息体的 SHA-256
+CREATE TABLE IF NOT EXISTS message_inbox (
+    queue        VARCHAR(191) NOT NULL,
+    message_id   VARCHAR(191) NOT NULL,
+    processed_at DATETIME(3)  NOT NULL,
+    expires_at   DATETIME(3)  NOT NULL,
+    PRIMARY KEY (queue, message_id),
+    INDEX idx_message_inbox_expires_at (expires_at)
+);
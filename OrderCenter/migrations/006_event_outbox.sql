@@ -0,0 +1,17 @@
+-- 事件发件箱：待推送的订单事件与订单变更在同一事务中写入，由 OutboxRelay 按 id 顺序推送，broker 确认后删除
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id             BIGINT        NOT NULL AUTO_INCREMENT,
+    event_id       VARCHAR(64)   NOT NULL,
+    event_type     VARCHAR(64)   NOT NULL,
+    order_id       VARCHAR(191)  NOT NULL,
+    exchange       VARCHAR(191)  NOT NULL,
+    routing_key    VARCHAR(255)  NOT NULL,
+    content_type   VARCHAR(64)   NOT NULL,
+    body           MEDIUMBLOB    NOT NULL,
+    correlation_id VARCHAR(64)   NOT NULL DEFAULT '',
+    headers        JSON          NULL,
+    attempts       INT           NOT NULL DEFAULT 0,
+    last_error     VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at     DATETIME(3)   NOT NULL,
+    PRIMARY KEY (id)
+);
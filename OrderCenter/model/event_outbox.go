@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// EventOutbox 待推送的订单事件。
+// 与订单变更在同一事务中写入，变更提交后事件一定会被推送；OutboxRelay 按 ID 顺序推送，broker 确认后删除。
+type EventOutbox struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EventID    string `gorm:"column:event_id" json:"event_id"`
+	EventType  string `gorm:"column:event_type" json:"event_type"`
+	OrderID    string `gorm:"column:order_id" json:"order_id"`
+	Exchange   string `gorm:"column:exchange" json:"exchange"`
+	RoutingKey string `gorm:"column:routing_key" json:"routing_key"`
+	// 写入时已按交换机的编码序列化
+	ContentType string `gorm:"column:content_type" json:"content_type"`
+	Body        []byte `gorm:"column:body" json:"-"`
+	// 写入时的请求 ID 和 trace 消息头，推送时带上，下游能接上同一条链路
+	CorrelationID string `gorm:"column:correlation_id" json:"correlation_id"`
+	Headers       JSONB  `gorm:"column:headers;type:json" json:"headers"`
+
+	// 推送失败的次数和最近一次的原因
+	Attempts  int       `gorm:"column:attempts" json:"attempts"`
+	LastError string    `gorm:"column:last_error" json:"last_error"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (EventOutbox) TableName() string {
+	return "event_outbox"
+}
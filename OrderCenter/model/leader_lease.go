@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// LeaderLease 单例任务的领导权租约。
+// FencingToken 每次换主时递增，旧主持有的 token 会失效。
+type LeaderLease struct {
+	Name         string    `gorm:"column:name;primaryKey" json:"name"`
+	Holder       string    `gorm:"column:holder" json:"holder"`
+	FencingToken int64     `gorm:"column:fencing_token" json:"fencing_token"`
+	ExpiresAt    time.Time `gorm:"column:expires_at" json:"expires_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (LeaderLease) TableName() string {
+	return "leader_lease"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// MessageInbox 已处理的队列消息，用于消费端去重。
+// 记录与订单变更在同一事务中写入，过期后同一消息可以再次处理。
+type MessageInbox struct {
+	Queue       string    `gorm:"column:queue;primaryKey" json:"queue"`
+	MessageID   string    `gorm:"column:message_id;primaryKey" json:"message_id"`
+	ProcessedAt time.Time `gorm:"column:processed_at" json:"processed_at"`
+	ExpiresAt   time.Time `gorm:"column:expires_at" json:"expires_at"`
+}
+
+func (MessageInbox) TableName() string {
+	return "message_inbox"
+}
@@ -27,6 +27,22 @@ func (j *JSONB) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]interface{}(*j))
 }
 
+// 订单中心自己维护的状态，其余状态值由上游消息决定
+const (
+	// 已入库但尚未到生效时间，到期后由调度器推送
+	OrderStatusScheduled = "scheduled"
+	// 超过 expires_at 后由过期清理任务置为 expired
+	OrderStatusExpired = "expired"
+	// 已撤单的订单，记录保留，之后不会再过期、更新或被撤单
+	OrderStatusWithdrawn = "withdrawn"
+)
+
+// TerminalStatuses 终态，处于终态的订单不再参与过期和撤单
+var TerminalStatuses = []string{OrderStatusExpired, OrderStatusWithdrawn}
+
+// 调度订单在 Metadata 中暂存原始状态的 key，生效时恢复
+const MetadataPendingStatus = "pending_status"
+
 type OrderData struct {
 	OrderID        string `gorm:"column:order_id;primaryKey" json:"order_id"`
 	Status         string `gorm:"column:status" json:"status"`
@@ -41,6 +57,11 @@ type OrderData struct {
 	ChainIndex   int    `gorm:"column:chain_index" json:"chain_index"`
 	EventType    string `gorm:"column:event_type" json:"event_type"`
 
+	// 生效时间，为空表示立即生效
+	ActivateAt *time.Time `gorm:"column:activate_at" json:"activate_at,omitempty"`
+	// 过期时间，为空表示不过期
+	ExpiresAt *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"`
+
 	Metadata  JSONB     `gorm:"column:metadata;type:json" json:"metadata"`
 	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
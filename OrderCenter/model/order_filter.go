@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// OrderFilter 订单列表和统计共用的过滤条件，零值字段不参与过滤
+type OrderFilter struct {
+	// 多个状态用逗号分隔
+	Status       string     `form:"status"`
+	StrategyID   *int64     `form:"strategy_id"`
+	UserID       string     `form:"user_id"`
+	ChainIndex   *int       `form:"chain_index"`
+	TokenAddress string     `form:"token_address"`
+	EventType    string     `form:"event_type"`
+	CreatedFrom  *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo    *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// WithdrawScope 批量撤单的范围，至少指定一项，多项之间为 AND
+type WithdrawScope struct {
+	StrategyID   *int64 `json:"strategy_id,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	TokenAddress string `json:"token_address,omitempty"`
+	ChainIndex   *int   `json:"chain_index,omitempty"`
+}
+
+// Empty 未指定任何条件
+func (s WithdrawScope) Empty() bool {
+	return s.StrategyID == nil && s.UserID == "" && s.TokenAddress == "" && s.ChainIndex == nil
+}
+
+// 统计可用的时间字段
+const (
+	StatsTimeCreatedAt      = "created_at"
+	StatsTimeEventTimestamp = "event_timestamp" // Unix 毫秒
+)
+
+// 统计的时间粒度
+const (
+	StatsIntervalMinute = "minute"
+	StatsIntervalHour   = "hour"
+	StatsIntervalDay    = "day"
+	StatsIntervalMonth  = "month"
+)
+
+// OrderStatsQuery 订单统计查询
+type OrderStatsQuery struct {
+	Filter OrderFilter
+	// 分组字段：status / strategy_id / user_id / chain_index / token_address / event_type
+	GroupBy []string
+	// 时间分桶粒度，为空表示不分桶
+	Interval string
+	// 分桶使用的时间字段，默认 created_at
+	TimeField string
+}
+
+// OrderStatsRow 一个分组的订单数
+type OrderStatsRow struct {
+	Group map[string]interface{} `json:"group"`
+	// 分桶起始时间（数据库时区），未分桶时为空
+	Bucket string `json:"bucket,omitempty"`
+	Count  int64  `json:"count"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// 订单历史动作
+const (
+	HistoryActionExpire   = "expire"
+	HistoryActionWithdraw = "withdraw"
+	HistoryActionUpdate   = "update"
+)
+
+// OrderHistory 订单状态变更记录，Snapshot 保存变更前的订单
+type OrderHistory struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OrderID    string    `gorm:"column:order_id" json:"order_id"`
+	Action     string    `gorm:"column:action" json:"action"`
+	FromStatus string    `gorm:"column:from_status" json:"from_status"`
+	ToStatus   string    `gorm:"column:to_status" json:"to_status"`
+	Snapshot   JSONB     `gorm:"column:snapshot;type:json" json:"snapshot"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (OrderHistory) TableName() string {
+	return "order_history"
+}
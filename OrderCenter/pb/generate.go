@@ -0,0 +1,5 @@
+// Package pb 订单中心队列消息的 Protobuf 定义（order.proto）和生成代码。
+// 导入本包后消息类型注册到全局 registry，utils 中的 protobuf 编解码器按名称查找。
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative order.proto
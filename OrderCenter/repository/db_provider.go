@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// DBProvider 持有当前的数据库连接。
+// 重连时原子替换连接，仓库每次访问数据库都通过 Get 取最新连接，
+// 不会继续使用已失效的旧连接。
+type DBProvider struct {
+	db      atomic.Pointer[gorm.DB]
+	healthy atomic.Bool
+}
+
+func NewDBProvider(db *gorm.DB) *DBProvider {
+	p := &DBProvider{}
+	p.db.Store(db)
+	p.healthy.Store(true)
+	return p
+}
+
+// Get 返回当前连接
+func (p *DBProvider) Get() *gorm.DB {
+	return p.db.Load()
+}
+
+// Swap 替换为新连接并返回旧连接，由调用方负责关闭旧连接
+func (p *DBProvider) Swap(db *gorm.DB) *gorm.DB {
+	return p.db.Swap(db)
+}
+
+// Healthy 最近一次健康检查是否通过
+func (p *DBProvider) Healthy() bool {
+	return p.healthy.Load()
+}
+
+func (p *DBProvider) SetHealthy(healthy bool) {
+	p.healthy.Store(healthy)
+}
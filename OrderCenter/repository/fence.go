@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Fence 单例任务所持领导权的任期，Token 为获得租约时的 fencing_token
+type Fence struct {
+	Lease string
+	Token int64
+}
+
+type fenceCtx struct{}
+
+// WithFence 让 ctx 下的单例任务写操作带上 fencing token
+func WithFence(ctx context.Context, lease string, token int64) context.Context {
+	return context.WithValue(ctx, fenceCtx{}, Fence{Lease: lease, Token: token})
+}
+
+func fenceFrom(ctx context.Context) (Fence, bool) {
+	f, ok := ctx.Value(fenceCtx{}).(Fence)
+	return f, ok
+}
+
+// fenceCondition 租约仍属于这一任期且未过期。
+// 旧主节点在失去领导权后、任务退出前执行的写操作因条件不成立而不生效。
+const fenceCondition = "EXISTS (SELECT 1 FROM leader_lease WHERE name = ? AND fencing_token = ? AND expires_at > NOW(3))"
+
+// fenced ctx 带有 fence 时在写操作的 WHERE 中追加 fenceCondition
+func fenced(ctx context.Context, db *gorm.DB) *gorm.DB {
+	f, ok := fenceFrom(ctx)
+	if !ok {
+		return db
+	}
+	return db.Where(fenceCondition, f.Lease, f.Token)
+}
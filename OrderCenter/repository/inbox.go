@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"trade-solution/ordercenter/model"
+)
+
+// claimMessageSQL 登记一条消息：不存在时插入，存在但已过期时覆盖，未过期时不修改
+const claimMessageSQL = `INSERT INTO message_inbox (queue, message_id, processed_at, expires_at) VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+    processed_at = IF(expires_at <= ?, VALUES(processed_at), processed_at),
+    expires_at = IF(expires_at <= ?, VALUES(expires_at), expires_at)`
+
+// ClaimMessages 在收件箱中登记消息，返回本次登记成功的 ID；已登记且未过期的消息不在其中。
+// 在事务中调用时与订单变更一起提交或回滚。
+// 每条消息一条 INSERT ... ON DUPLICATE KEY UPDATE，影响行数 1 为新插入、2 为覆盖过期记录、0 为已处理；
+// 并发登记同一条消息时后到的一方等待先到的事务结束，之后得到 0。
+// 依赖 MySQL 默认的影响行数语义，DSN 不能开启 clientFoundRows。
+func (r *OrderRepository) ClaimMessages(ctx context.Context, queue string, ids []string, now time.Time, ttl time.Duration) (map[string]bool, error) {
+	db := r.db(ctx)
+	expiresAt := now.Add(ttl)
+	claimed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if claimed[id] {
+			continue
+		}
+		res := db.Exec(claimMessageSQL, queue, id, now, expiresAt, now, now)
+		if res.Error != nil {
+			// 主键冲突说明另一事务已登记
+			if IsDuplicateKey(res.Error) {
+				continue
+			}
+			return nil, res.Error
+		}
+		if res.RowsAffected > 0 {
+			claimed[id] = true
+		}
+	}
+	return claimed, nil
+}
+
+// MessageProcessed 消息是否已登记且未过期
+func (r *OrderRepository) MessageProcessed(ctx context.Context, queue, id string, now time.Time) (bool, error) {
+	var count int64
+	err := r.db(ctx).Model(&model.MessageInbox{}).
+		Where("queue = ? AND message_id = ? AND expires_at > ?", queue, id, now).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpiredMessages 删除过期的收件箱记录，每次最多 limit 条，返回删除的行数。
+// ctx 带有 fence 时领导权已转移则不删除。
+func (r *OrderRepository) DeleteExpiredMessages(ctx context.Context, now time.Time, limit int) (int64, error) {
+	res := fenced(ctx, r.db(ctx)).Where("expires_at <= ?", now).Limit(limit).Delete(&model.MessageInbox{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gomysql "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockProvider 返回使用 sqlmock 连接的 DBProvider
+func newMockProvider(t *testing.T) (*DBProvider, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDBProvider(db), mock
+}
+
+func TestClaimMessages(t *testing.T) {
+	provider, mock := newMockProvider(t)
+	repo := NewOrderRepository(provider)
+	now := time.Now()
+	claim := regexp.QuoteMeta("INSERT INTO message_inbox")
+
+	// m-1 新消息，m-2 已处理且未过期，m-3 记录已过期被覆盖，m-4 被并发事务登记；m-1 重复出现只登记一次
+	mock.ExpectExec(claim).WithArgs("q", "m-1", now, now.Add(time.Hour), now, now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(claim).WithArgs("q", "m-2", now, now.Add(time.Hour), now, now).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(claim).WithArgs("q", "m-3", now, now.Add(time.Hour), now, now).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(claim).WithArgs("q", "m-4", now, now.Add(time.Hour), now, now).
+		WillReturnError(&gomysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+	claimed, err := repo.ClaimMessages(context.Background(), "q", []string{"m-1", "m-2", "m-3", "m-4", "m-1"}, now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"m-1": true, "m-3": true}
+	if len(claimed) != len(want) || !claimed["m-1"] || !claimed["m-3"] {
+		t.Fatalf("claimed = %v, want %v", claimed, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimMessagesError(t *testing.T) {
+	provider, mock := newMockProvider(t)
+	repo := NewOrderRepository(provider)
+	errDB := errors.New("db down")
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO message_inbox")).WillReturnError(errDB)
+
+	if _, err := repo.ClaimMessages(context.Background(), "q", []string{"m-1", "m-2"}, time.Now(), time.Hour); !errors.Is(err, errDB) {
+		t.Fatalf("err = %v, want %v", err, errDB)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteExpiredMessages(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		args  []driver.Value
+	}{
+		{"不带 fence", context.Background(),
+			"DELETE FROM `message_inbox` WHERE expires_at <= ? LIMIT ?",
+			[]driver.Value{now, 500}},
+		{"带 fence", WithFence(context.Background(), "inbox_cleanup", 7),
+			"DELETE FROM `message_inbox` WHERE (" + fenceCondition + ") AND expires_at <= ? LIMIT ?",
+			[]driver.Value{"inbox_cleanup", int64(7), now, 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, mock := newMockProvider(t)
+			repo := NewOrderRepository(provider)
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(tt.query)).WithArgs(tt.args...).WillReturnResult(sqlmock.NewResult(0, 3))
+			mock.ExpectCommit()
+
+			n, err := repo.DeleteExpiredMessages(tt.ctx, now, 500)
+			if err != nil || n != 3 {
+				t.Fatalf("DeleteExpiredMessages = %d, %v", n, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"trade-solution/ordercenter/model"
+
+	"gorm.io/gorm/clause"
+)
+
+type LeaseRepository struct {
+	provider *DBProvider
+}
+
+func NewLeaseRepository(provider *DBProvider) *LeaseRepository {
+	return &LeaseRepository{provider: provider}
+}
+
+// TryAcquire 获取或续约租约，返回当前租约以及是否由 holder 持有。
+// 过期判断使用数据库时间，避免各实例时钟不一致。
+func (r *LeaseRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (*model.LeaderLease, bool, error) {
+	db := r.provider.Get().WithContext(ctx)
+
+	// 首次使用时创建租约行，已存在则忽略
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.LeaderLease{
+		Name:      name,
+		ExpiresAt: time.Unix(0, 0),
+	}).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 换主时 fencing_token 加一，必须在修改 holder 之前计算
+	res := db.Exec(`UPDATE leader_lease
+		SET fencing_token = IF(holder = ?, fencing_token, fencing_token + 1),
+			holder = ?,
+			expires_at = NOW(3) + INTERVAL ? MICROSECOND,
+			updated_at = NOW(3)
+		WHERE name = ? AND (holder = ? OR expires_at < NOW(3))`,
+		holder, holder, ttl.Microseconds(), name, holder)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	var lease model.LeaderLease
+	if err := db.First(&lease, "name = ?", name).Error; err != nil {
+		return nil, false, err
+	}
+	return &lease, res.RowsAffected == 1 && lease.Holder == holder, nil
+}
+
+// Release 主动释放租约，其他实例可以立即接管
+func (r *LeaseRepository) Release(ctx context.Context, name, holder string) error {
+	return r.provider.Get().WithContext(ctx).Exec(`UPDATE leader_lease SET expires_at = NOW(3) WHERE name = ? AND holder = ?`, name, holder).Error
+}
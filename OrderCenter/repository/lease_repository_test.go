@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	leaseInsertSQL = "INSERT INTO `leader_lease`"
+	leaseUpdateSQL = "UPDATE leader_lease\\s+SET fencing_token = IF\\(holder = \\?, fencing_token, fencing_token \\+ 1\\)"
+	leaseSelectSQL = "SELECT \\* FROM `leader_lease` WHERE name = \\?"
+)
+
+var leaseColumns = []string{"name", "holder", "fencing_token", "expires_at", "updated_at"}
+
+// expectTryAcquire 一次 TryAcquire：创建租约行、条件更新、读回租约
+func expectTryAcquire(mock sqlmock.Sqlmock, holder string, ttl time.Duration, updated int64, lease model.LeaderLease) {
+	mock.ExpectBegin()
+	mock.ExpectExec(leaseInsertSQL).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec(leaseUpdateSQL).
+		WithArgs(holder, holder, ttl.Microseconds(), lease.Name, holder).
+		WillReturnResult(sqlmock.NewResult(0, updated))
+	mock.ExpectQuery(leaseSelectSQL).WillReturnRows(sqlmock.NewRows(leaseColumns).
+		AddRow(lease.Name, lease.Holder, lease.FencingToken, lease.ExpiresAt, time.Now()))
+}
+
+func TestTryAcquire(t *testing.T) {
+	const ttl = 15 * time.Second
+	expires := time.Now().Add(ttl)
+	tests := []struct {
+		name string
+		// 条件更新影响的行数：持有者本人续约或租约已过期时为 1
+		updated int64
+		lease   model.LeaderLease
+		held    bool
+	}{
+		{"首次获取", 1, model.LeaderLease{Name: "jobs", Holder: "a", FencingToken: 1, ExpiresAt: expires}, true},
+		{"续约", 1, model.LeaderLease{Name: "jobs", Holder: "a", FencingToken: 1, ExpiresAt: expires}, true},
+		{"接管过期租约", 1, model.LeaderLease{Name: "jobs", Holder: "a", FencingToken: 2, ExpiresAt: expires}, true},
+		{"租约由其他实例持有", 0, model.LeaderLease{Name: "jobs", Holder: "b", FencingToken: 2, ExpiresAt: expires}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, mock := newMockProvider(t)
+			repo := NewLeaseRepository(provider)
+			expectTryAcquire(mock, "a", ttl, tt.updated, tt.lease)
+
+			lease, held, err := repo.TryAcquire(context.Background(), "jobs", "a", ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if held != tt.held || lease.Holder != tt.lease.Holder || lease.FencingToken != tt.lease.FencingToken {
+				t.Fatalf("held = %v, lease = %+v", held, lease)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	provider, mock := newMockProvider(t)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE leader_lease SET expires_at = NOW(3) WHERE name = ? AND holder = ?")).
+		WithArgs("jobs", "a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := NewLeaseRepository(provider).Release(context.Background(), "jobs", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 写操作带上本任期的 fencing token，换主后 token 不再匹配，写入不生效
+func TestFencedWrites(t *testing.T) {
+	fence := regexp.QuoteMeta(fenceCondition)
+	ctx := WithFence(context.Background(), "jobs", 1)
+
+	t.Run("任期内写入", func(t *testing.T) {
+		provider, mock := newMockProvider(t)
+		repo := NewOrderRepository(provider)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE `order_data` SET .* WHERE \\("+fence+"\\) AND \\(order_id = \\? AND status = \\?\\)").
+			WithArgs(model.OrderStatusExpired, sqlmock.AnyArg(), "jobs", int64(1), "o-1", "open").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ok, err := repo.CompareAndSetStatus(ctx, "o-1", "open", model.OrderStatusExpired)
+		if err != nil || !ok {
+			t.Fatalf("ok = %v, err = %v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("换主后写入被拒绝", func(t *testing.T) {
+		provider, mock := newMockProvider(t)
+		repo := NewOrderRepository(provider)
+		// 新主节点已把 fencing_token 加到 2，旧 token 的 EXISTS 条件不成立
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE `order_data` SET .* WHERE \\("+fence+"\\)").
+			WithArgs(model.OrderStatusExpired, sqlmock.AnyArg(), "jobs", int64(1), "o-1", "open").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM `event_outbox` WHERE \\("+fence+"\\) AND id = \\?").
+			WithArgs("jobs", int64(1), int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		ok, err := repo.CompareAndSetStatus(ctx, "o-1", "open", model.OrderStatusExpired)
+		if err != nil || ok {
+			t.Fatalf("ok = %v, err = %v, want 写入不生效", ok, err)
+		}
+		if err := repo.DeleteOutboxEvent(ctx, 7); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("不带 fence 不追加条件", func(t *testing.T) {
+		provider, mock := newMockProvider(t)
+		repo := NewOrderRepository(provider)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `event_outbox` WHERE id = ?")).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := repo.DeleteOutboxEvent(context.Background(), 7); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
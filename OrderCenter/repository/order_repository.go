@@ -1,39 +1,254 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
 	"trade-solution/ordercenter/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// 批量插入时单条 INSERT 的最大行数
+const batchInsertSize = 200
+
 type OrderRepository struct {
-	DB *gorm.DB
+	provider *DBProvider
+	tx       *gorm.DB // 非空时表示事务内的仓库
+}
+
+func NewOrderRepository(provider *DBProvider) *OrderRepository {
+	return &OrderRepository{provider: provider}
 }
 
-func NewOrderRepository(db *gorm.DB) *OrderRepository {
-	return &OrderRepository{DB: db}
+// db 返回本次操作使用的连接：事务内用事务连接，否则取当前最新连接。
+// ctx 的超时和取消会传递到 SQL 执行。
+func (r *OrderRepository) db(ctx context.Context) *gorm.DB {
+	if r.tx != nil {
+		return r.tx.WithContext(ctx)
+	}
+	return r.provider.Get().WithContext(ctx)
 }
 
-func (r *OrderRepository) Create(order *model.OrderData) error {
-	return r.DB.Create(order).Error
+func (r *OrderRepository) Create(ctx context.Context, order *model.OrderData) error {
+	return r.db(ctx).Create(order).Error
 }
 
-func (r *OrderRepository) GetByID(orderID string) (*model.OrderData, error) {
+func (r *OrderRepository) GetByID(ctx context.Context, orderID string) (*model.OrderData, error) {
 	var order model.OrderData
-	err := r.DB.First(&order, "order_id = ?", orderID).Error
+	err := r.db(ctx).First(&order, "order_id = ?", orderID).Error
 	return &order, err
 }
 
-func (r *OrderRepository) Update(orderID string, updated *model.OrderData) error {
-	return r.DB.Model(&model.OrderData{}).Where("order_id = ?", orderID).Updates(updated).Error
+func (r *OrderRepository) Update(ctx context.Context, orderID string, updated *model.OrderData) error {
+	return r.db(ctx).Model(&model.OrderData{}).Where("order_id = ?", orderID).Updates(updated).Error
+}
+
+func (r *OrderRepository) Delete(ctx context.Context, orderID string) error {
+	return r.db(ctx).Delete(&model.OrderData{}, "order_id = ?", orderID).Error
+}
+
+func (r *OrderRepository) GetAll(ctx context.Context, filter model.OrderFilter) ([]model.OrderData, error) {
+	var orders []model.OrderData
+	err := applyFilter(r.db(ctx), filter).Find(&orders).Error
+	return orders, err
+}
+
+// applyFilter 把过滤条件转为 WHERE 子句
+func applyFilter(db *gorm.DB, f model.OrderFilter) *gorm.DB {
+	if f.Status != "" {
+		db = db.Where("status IN ?", strings.Split(f.Status, ","))
+	}
+	if f.StrategyID != nil {
+		db = db.Where("strategy_id = ?", *f.StrategyID)
+	}
+	if f.UserID != "" {
+		db = db.Where("user_id = ?", f.UserID)
+	}
+	if f.ChainIndex != nil {
+		db = db.Where("chain_index = ?", *f.ChainIndex)
+	}
+	if f.TokenAddress != "" {
+		db = db.Where("token_address = ?", f.TokenAddress)
+	}
+	if f.EventType != "" {
+		db = db.Where("event_type = ?", f.EventType)
+	}
+	if f.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		db = db.Where("created_at < ?", *f.CreatedTo)
+	}
+	return db
 }
 
-func (r *OrderRepository) Delete(orderID string) error {
-	return r.DB.Delete(&model.OrderData{}, "order_id = ?", orderID).Error
+// FindExistingIDs 返回 ids 中已存在的订单 ID
+func (r *OrderRepository) FindExistingIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	var found []string
+	err := r.db(ctx).Model(&model.OrderData{}).
+		Where("order_id IN ?", ids).
+		Pluck("order_id", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
 }
 
-func (r *OrderRepository) GetAll() ([]model.OrderData, error) {
+// FindStatuses 返回 ids 中已存在订单的当前状态
+func (r *OrderRepository) FindStatuses(ctx context.Context, ids []string) (map[string]string, error) {
+	var rows []struct {
+		OrderID string
+		Status  string
+	}
+	err := r.db(ctx).Model(&model.OrderData{}).
+		Select("order_id, status").
+		Where("order_id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]string, len(rows))
+	for _, row := range rows {
+		statuses[row.OrderID] = row.Status
+	}
+	return statuses, nil
+}
+
+// CreateBatch 多行插入，每条 INSERT 最多 batchInsertSize 行
+func (r *OrderRepository) CreateBatch(ctx context.Context, orders []*model.OrderData) error {
+	return r.db(ctx).CreateInBatches(orders, batchInsertSize).Error
+}
+
+// CreateBatchSkipExisting 多行插入，主键已存在的行跳过而不是报错，返回实际插入的行数。
+// 与其他事务并发写入同一订单时不会整条 INSERT 失败，调用方按行数判断是否有订单被跳过。
+func (r *OrderRepository) CreateBatchSkipExisting(ctx context.Context, orders []*model.OrderData) (int64, error) {
+	res := r.db(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(orders, batchInsertSize)
+	return res.RowsAffected, res.Error
+}
+
+// UpdateStatusByIDs 按 ID 批量修改状态，返回修改的行数
+func (r *OrderRepository) UpdateStatusByIDs(ctx context.Context, ids []string, status string) (int64, error) {
+	res := r.db(ctx).Model(&model.OrderData{}).
+		Where("order_id IN ?", ids).
+		Update("status", status)
+	return res.RowsAffected, res.Error
+}
+
+// FindDueScheduled 查询已到生效时间的调度订单
+func (r *OrderRepository) FindDueScheduled(ctx context.Context, now time.Time, limit int) ([]model.OrderData, error) {
 	var orders []model.OrderData
-	err := r.DB.Find(&orders).Error
+	err := r.db(ctx).
+		Where("status = ? AND activate_at <= ?", model.OrderStatusScheduled, now).
+		Order("activate_at").
+		Limit(limit).
+		Find(&orders).Error
 	return orders, err
 }
+
+// CompareAndSetStatus 仅当订单当前状态为 from 时改为 to，返回是否修改成功。
+// 多个实例同时处理同一订单时只有一个能成功；ctx 带有 fence 时领导权已转移也不会修改。
+func (r *OrderRepository) CompareAndSetStatus(ctx context.Context, orderID, from, to string) (bool, error) {
+	res := fenced(ctx, r.db(ctx)).Model(&model.OrderData{}).
+		Where("order_id = ? AND status = ?", orderID, from).
+		Update("status", to)
+	return res.RowsAffected == 1, res.Error
+}
+
+// ActivateScheduled 把到期的调度订单改为 status，并在同一条 UPDATE 中删除暂存的原始状态，返回是否修改成功。
+// 仅当订单仍为 scheduled 时修改，多个实例同时激活或与撤单并发时只有一个能成功。
+func (r *OrderRepository) ActivateScheduled(ctx context.Context, orderID, status string) (bool, error) {
+	res := fenced(ctx, r.db(ctx)).Model(&model.OrderData{}).
+		Where("order_id = ? AND status = ?", orderID, model.OrderStatusScheduled).
+		Updates(map[string]interface{}{
+			"status":   status,
+			"metadata": gorm.Expr("JSON_REMOVE(metadata, ?)", "$."+model.MetadataPendingStatus),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// Transaction 在同一事务中执行 fn，fn 中的仓库操作共用事务连接
+func (r *OrderRepository) Transaction(ctx context.Context, fn func(tx *OrderRepository) error) error {
+	return r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&OrderRepository{provider: r.provider, tx: tx})
+	})
+}
+
+// FindExpired 查询已过期但尚未进入终态的订单
+func (r *OrderRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]model.OrderData, error) {
+	var orders []model.OrderData
+	err := r.db(ctx).
+		Where("expires_at <= ? AND status NOT IN ?", now, model.TerminalStatuses).
+		Order("expires_at").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+func (r *OrderRepository) CreateHistory(ctx context.Context, history *model.OrderHistory) error {
+	return r.db(ctx).Create(history).Error
+}
+
+// CreateHistoryBatch 多行插入历史记录
+func (r *OrderRepository) CreateHistoryBatch(ctx context.Context, histories []*model.OrderHistory) error {
+	return r.db(ctx).CreateInBatches(histories, batchInsertSize).Error
+}
+
+// LockOpenByIDs 在事务中查询并锁定指定的未进入终态的订单
+func (r *OrderRepository) LockOpenByIDs(ctx context.Context, ids []string) ([]model.OrderData, error) {
+	var orders []model.OrderData
+	err := r.db(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id IN ? AND status NOT IN ?", ids, model.TerminalStatuses).
+		Order("order_id").
+		Find(&orders).Error
+	return orders, err
+}
+
+// LockOpenByScope 在事务中查询并锁定范围内未进入终态的订单，scope 不能为空
+func (r *OrderRepository) LockOpenByScope(ctx context.Context, scope model.WithdrawScope, limit int) ([]model.OrderData, error) {
+	db := r.db(ctx).Where("status NOT IN ?", model.TerminalStatuses)
+	if scope.StrategyID != nil {
+		db = db.Where("strategy_id = ?", *scope.StrategyID)
+	}
+	if scope.UserID != "" {
+		db = db.Where("user_id = ?", scope.UserID)
+	}
+	if scope.TokenAddress != "" {
+		db = db.Where("token_address = ?", scope.TokenAddress)
+	}
+	if scope.ChainIndex != nil {
+		db = db.Where("chain_index = ?", *scope.ChainIndex)
+	}
+	var orders []model.OrderData
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Order("order_id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CountByStatus 按状态统计订单数
+func (r *OrderRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db(ctx).Model(&model.OrderData{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"trade-solution/ordercenter/model"
+)
+
+// 单次统计最多返回的分组数
+const statsMaxRows = 10000
+
+// ErrStatsTooManyRows 分组数超过上限，需要缩小过滤范围、减少分组字段或加大时间粒度
+var ErrStatsTooManyRows = fmt.Errorf("统计分组数超过 %d", statsMaxRows)
+
+// statsGroupColumns 允许分组的列，值表示是否为整数列
+var statsGroupColumns = map[string]bool{
+	"status":        false,
+	"strategy_id":   true,
+	"user_id":       false,
+	"chain_index":   true,
+	"token_address": false,
+	"event_type":    false,
+}
+
+// statsTimeExprs 分桶使用的时间表达式，event_timestamp 为 Unix 毫秒
+var statsTimeExprs = map[string]string{
+	model.StatsTimeCreatedAt:      "created_at",
+	model.StatsTimeEventTimestamp: "FROM_UNIXTIME(event_timestamp / 1000)",
+}
+
+// statsIntervalFormats 各粒度对应的 DATE_FORMAT 格式，格式化结果即分桶起始时间
+var statsIntervalFormats = map[string]string{
+	model.StatsIntervalMinute: "%Y-%m-%d %H:%i:00",
+	model.StatsIntervalHour:   "%Y-%m-%d %H:00:00",
+	model.StatsIntervalDay:    "%Y-%m-%d 00:00:00",
+	model.StatsIntervalMonth:  "%Y-%m-01 00:00:00",
+}
+
+// IsStatsGroupColumn 是否允许按该列分组
+func IsStatsGroupColumn(col string) bool {
+	_, ok := statsGroupColumns[col]
+	return ok
+}
+
+// IsStatsTimeField 是否允许按该字段分桶
+func IsStatsTimeField(field string) bool {
+	_, ok := statsTimeExprs[field]
+	return ok
+}
+
+// IsStatsInterval 是否为支持的分桶粒度
+func IsStatsInterval(interval string) bool {
+	_, ok := statsIntervalFormats[interval]
+	return ok
+}
+
+// CountGrouped 按分组字段和时间桶统计订单数，分组和计数都在 SQL 中完成。
+// 分组列和时间表达式只取自白名单，调用方需先校验参数。
+// 分组数超过 statsMaxRows 时返回 ErrStatsTooManyRows，不返回截断的结果。
+func (r *OrderRepository) CountGrouped(ctx context.Context, q model.OrderStatsQuery) ([]model.OrderStatsRow, error) {
+	selects := make([]string, 0, len(q.GroupBy)+2)
+	groups := make([]string, 0, len(q.GroupBy)+1)
+
+	bucketed := q.Interval != ""
+	if bucketed {
+		expr, ok := statsTimeExprs[q.TimeField]
+		if !ok {
+			return nil, fmt.Errorf("不支持的时间字段: %s", q.TimeField)
+		}
+		format, ok := statsIntervalFormats[q.Interval]
+		if !ok {
+			return nil, fmt.Errorf("不支持的时间粒度: %s", q.Interval)
+		}
+		selects = append(selects, fmt.Sprintf("DATE_FORMAT(%s, '%s') AS bucket", expr, format))
+		groups = append(groups, "bucket")
+	}
+	for _, col := range q.GroupBy {
+		if !IsStatsGroupColumn(col) {
+			return nil, fmt.Errorf("不支持的分组字段: %s", col)
+		}
+		selects = append(selects, col)
+		groups = append(groups, col)
+	}
+	selects = append(selects, "COUNT(*) AS count")
+
+	db := applyFilter(r.db(ctx).Model(&model.OrderData{}), q.Filter).
+		Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		db = db.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+	// 多取一行用来判断是否超过上限
+	rows, err := db.Limit(statsMaxRows + 1).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]model.OrderStatsRow, 0)
+	for rows.Next() {
+		if len(result) == statsMaxRows {
+			return nil, ErrStatsTooManyRows
+		}
+		var bucket sql.NullString
+		var count int64
+		values := make([]interface{}, len(q.GroupBy))
+		dest := make([]interface{}, 0, len(q.GroupBy)+2)
+		if bucketed {
+			dest = append(dest, &bucket)
+		}
+		for i, col := range q.GroupBy {
+			if statsGroupColumns[col] {
+				values[i] = new(sql.NullInt64)
+			} else {
+				values[i] = new(sql.NullString)
+			}
+			dest = append(dest, values[i])
+		}
+		dest = append(dest, &count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := model.OrderStatsRow{Group: make(map[string]interface{}, len(q.GroupBy)), Bucket: bucket.String, Count: count}
+		for i, col := range q.GroupBy {
+			// NULL 输出为 null
+			row.Group[col] = nil
+			switch v := values[i].(type) {
+			case *sql.NullInt64:
+				if v.Valid {
+					row.Group[col] = v.Int64
+				}
+			case *sql.NullString:
+				if v.Valid {
+					row.Group[col] = v.String
+				}
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
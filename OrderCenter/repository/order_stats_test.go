@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"trade-solution/ordercenter/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const statsSQL = "SELECT status, COUNT\\(\\*\\) AS count FROM `order_data` GROUP BY `status` ORDER BY status LIMIT \\?"
+
+func statsRows(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"status", "count"})
+	for i := 0; i < n; i++ {
+		rows.AddRow("open", int64(i))
+	}
+	return rows
+}
+
+func TestCountGrouped(t *testing.T) {
+	provider, mock := newMockProvider(t)
+	repo := NewOrderRepository(provider)
+	mock.ExpectQuery(statsSQL).WithArgs(statsMaxRows + 1).WillReturnRows(statsRows(statsMaxRows))
+
+	rows, err := repo.CountGrouped(context.Background(), model.OrderStatsQuery{GroupBy: []string{"status"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != statsMaxRows || rows[1].Group["status"] != "open" || rows[1].Count != 1 {
+		t.Fatalf("rows = %d, rows[1] = %+v", len(rows), rows[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 分组数超过上限时报错，不返回截断的结果
+func TestCountGroupedTooManyRows(t *testing.T) {
+	provider, mock := newMockProvider(t)
+	repo := NewOrderRepository(provider)
+	mock.ExpectQuery(statsSQL).WithArgs(statsMaxRows + 1).WillReturnRows(statsRows(statsMaxRows + 1))
+
+	rows, err := repo.CountGrouped(context.Background(), model.OrderStatsQuery{GroupBy: []string{"status"}})
+	if !errors.Is(err, ErrStatsTooManyRows) {
+		t.Fatalf("err = %v, want ErrStatsTooManyRows", err)
+	}
+	if rows != nil {
+		t.Fatalf("rows = %d, want nil", len(rows))
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"trade-solution/ordercenter/model"
+
+	"gorm.io/gorm"
+)
+
+// 推送失败原因的最大长度，与 last_error 列一致
+const maxOutboxErrorLen = 1024
+
+// CreateOutboxEvents 写入待推送的事件，需在订单变更的事务中调用
+func (r *OrderRepository) CreateOutboxEvents(ctx context.Context, events []*model.EventOutbox) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db(ctx).CreateInBatches(events, batchInsertSize).Error
+}
+
+// PendingOutboxEvents 按写入顺序返回最早的 limit 条待推送事件
+func (r *OrderRepository) PendingOutboxEvents(ctx context.Context, limit int) ([]model.EventOutbox, error) {
+	var events []model.EventOutbox
+	err := r.db(ctx).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// DeleteOutboxEvent 删除已推送的事件。ctx 带有 fence 时领导权已转移则不删除，由新的主节点再推送一次。
+func (r *OrderRepository) DeleteOutboxEvent(ctx context.Context, id int64) error {
+	return fenced(ctx, r.db(ctx)).Where("id = ?", id).Delete(&model.EventOutbox{}).Error
+}
+
+// RecordOutboxFailure 记录一次推送失败
+func (r *OrderRepository) RecordOutboxFailure(ctx context.Context, id int64, cause string) error {
+	if runes := []rune(cause); len(runes) > maxOutboxErrorLen {
+		cause = string(runes[:maxOutboxErrorLen])
+	}
+	return fenced(ctx, r.db(ctx)).Model(&model.EventOutbox{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": cause,
+		}).Error
+}
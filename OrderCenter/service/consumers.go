@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"trade-solution/ordercenter/config"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+)
+
+// consumerOptions 订单中心消费者的默认配置
+func consumerOptions(queue string, logger *slog.Logger) utils.ConsumerOptions {
+	logger = logger.With("component", "consumer", "queue", queue)
+	return utils.ConsumerOptions{
+		Queue:    queue,
+		Workers:  config.ConsumerWorkers,
+		Prefetch: config.ConsumerWorkers,
+		Retry:    utils.RetryPolicy{MaxAttempts: 3, Backoff: time.Second},
+		Timeout:  30 * time.Second,
+		Middleware: []utils.Middleware{
+			utils.LoggingMiddleware(logger),
+		},
+		Logger: logger,
+	}
+}
+
+// IngestBatch 新订单队列的批量写入配置，Size <= 1 时逐条写入
+type IngestBatch struct {
+	Size int
+	Wait time.Duration
+}
+
+// StartOrderConsumers 启动订单中心的全部消费者。
+// 新增队列只需在这里加一项：队列名 + 处理函数（可选批量处理函数）。
+func StartOrderConsumers(ctx context.Context, wg *sync.WaitGroup, rmq *utils.RabbitMQ, srv *OrderService, batch IngestBatch) ([]*utils.Consumer, error) {
+	handlers := []struct {
+		queue   string
+		handler utils.MessageHandler
+		batch   utils.BatchHandler
+	}{
+		{"multi_strategy_on_one_token_queue", func(ctx context.Context, d amqp.Delivery) error {
+			return handleMessage(ctx, d, srv)
+		}, func(ctx context.Context, items []utils.BatchItem) []error {
+			return handleMessageBatch(ctx, items, srv)
+		}},
+		{"withdraw_order_queue", func(ctx context.Context, d amqp.Delivery) error {
+			return withdrawMessage(ctx, d, srv)
+		}, nil},
+	}
+
+	consumers := make([]*utils.Consumer, 0, len(handlers))
+	for _, h := range handlers {
+		opts := consumerOptions(h.queue, srv.logger)
+		if h.batch != nil && batch.Size > 1 {
+			opts.Batch = utils.BatchOptions{Size: batch.Size, Wait: batch.Wait, Handler: dedupBatchHandler(h.queue, h.batch)}
+			// 每个 worker 要能同时持有一整批，上限在加载配置时校验
+			opts.Prefetch = opts.Workers * batch.Size
+		}
+		// 所有消费者都按消息 ID 去重，登记与订单变更在同一事务中
+		c := utils.NewConsumer(rmq, dedupHandler(h.queue, h.handler), opts)
+		if err := c.Start(ctx, wg); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/utils"
+)
+
+// 依赖状态
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// HealthCheck 检查一个依赖，detail 会原样出现在就绪接口的返回中
+type HealthCheck func(ctx context.Context) (detail interface{}, err error)
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	LatencyMs int64       `json:"latency_ms"`
+	Detail    interface{} `json:"detail,omitempty"`
+}
+
+// ReadinessReport 就绪检查结果，任一依赖不可用或正在停机时 Ready 为 false
+type ReadinessReport struct {
+	Ready        bool               `json:"ready"`
+	ShuttingDown bool               `json:"shutting_down"`
+	Checks       []DependencyStatus `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// HealthChecker 汇总各依赖的就绪状态
+type HealthChecker struct {
+	timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout}
+}
+
+// Register 注册依赖检查，按注册顺序输出
+func (h *HealthChecker) Register(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown 开始停机后就绪检查一律失败，负载均衡不再转发新请求
+func (h *HealthChecker) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Ready 并发执行全部检查，每项检查受 timeout 限制
+func (h *HealthChecker) Ready(ctx context.Context) ReadinessReport {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	results := make([]DependencyStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			detail, err := c.check(ctx)
+			results[i] = DependencyStatus{
+				Name:      c.name,
+				Status:    HealthUp,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Detail:    detail,
+			}
+			if err != nil {
+				results[i].Status = HealthDown
+				results[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	shuttingDown := h.shuttingDown.Load()
+	report := ReadinessReport{
+		Ready:        !shuttingDown,
+		ShuttingDown: shuttingDown,
+		Checks:       results,
+	}
+	for _, r := range results {
+		if r.Status != HealthUp {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// DBHealthCheck 对当前连接执行 ping
+func DBHealthCheck(provider *repository.DBProvider) HealthCheck {
+	return func(ctx context.Context) (interface{}, error) {
+		return nil, pingDB(ctx, provider.Get())
+	}
+}
+
+// RabbitMQHealthCheck 检查连接和发布通道
+func RabbitMQHealthCheck(rmq *utils.RabbitMQ) HealthCheck {
+	return func(ctx context.Context) (interface{}, error) {
+		status := rmq.Status()
+		switch {
+		case !status.ConnectionOpen:
+			return status, errors.New("连接已断开")
+		case !status.PublishChannelOpen:
+			return status, errors.New("发布通道已关闭")
+		}
+		return status, nil
+	}
+}
+
+// ConsumerStatus 消费者的订阅和 worker 状态
+type ConsumerStatus struct {
+	Subscribed     bool `json:"subscribed"`
+	RunningWorkers int  `json:"running_workers"`
+	Workers        int  `json:"workers"`
+}
+
+// ConsumerHealthCheck 检查消费者已订阅且所有 worker 都在运行
+func ConsumerHealthCheck(c *utils.Consumer) HealthCheck {
+	return func(ctx context.Context) (interface{}, error) {
+		status := ConsumerStatus{
+			Subscribed:     c.Subscribed(),
+			RunningWorkers: c.Running(),
+			Workers:        c.Workers(),
+		}
+		switch {
+		case !status.Subscribed:
+			return status, errors.New("未订阅队列")
+		case status.RunningWorkers < status.Workers:
+			return status, fmt.Errorf("worker 数不足: %d/%d", status.RunningWorkers, status.Workers)
+		}
+		return status, nil
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func upCheck(context.Context) (interface{}, error) { return "ok", nil }
+
+func TestHealthCheckerReady(t *testing.T) {
+	down := errors.New("连接被拒绝")
+	// 卡住的依赖等到检查超时
+	hang := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	tests := []struct {
+		name   string
+		checks map[string]HealthCheck
+		// 按注册顺序的期望状态
+		want      []string
+		wantReady bool
+	}{
+		{"全部可用", map[string]HealthCheck{"mysql": upCheck, "rabbitmq": upCheck}, []string{HealthUp, HealthUp}, true},
+		{"依赖不可用", map[string]HealthCheck{"mysql": upCheck, "rabbitmq": func(context.Context) (interface{}, error) { return nil, down }},
+			[]string{HealthUp, HealthDown}, false},
+		{"检查超时", map[string]HealthCheck{"mysql": hang, "rabbitmq": upCheck}, []string{HealthDown, HealthUp}, false},
+		{"未注册检查", nil, []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthChecker(20 * time.Millisecond)
+			for _, name := range []string{"mysql", "rabbitmq"} {
+				if check, ok := tt.checks[name]; ok {
+					h.Register(name, check)
+				}
+			}
+			report := h.Ready(context.Background())
+			if report.Ready != tt.wantReady || report.ShuttingDown || len(report.Checks) != len(tt.want) {
+				t.Fatalf("report = %+v", report)
+			}
+			for i, c := range report.Checks {
+				if c.Status != tt.want[i] || (c.Status == HealthDown) != (c.Error != "") {
+					t.Errorf("%s: status = %s, error = %q, want %s", c.Name, c.Status, c.Error, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 停机后即使依赖都可用也返回未就绪
+func TestHealthCheckerShuttingDown(t *testing.T) {
+	h := NewHealthChecker(time.Second)
+	h.Register("mysql", upCheck)
+	h.SetShuttingDown()
+
+	report := h.Ready(context.Background())
+	if report.Ready || !report.ShuttingDown || report.Checks[0].Status != HealthUp {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestDBHealthCheck(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := DBHealthCheck(repository.NewDBProvider(db))
+
+	mock.ExpectPing()
+	if _, err := check(context.Background()); err != nil {
+		t.Fatalf("ping 成功时 err = %v", err)
+	}
+	mock.ExpectPing().WillReturnError(errors.New("连接被拒绝"))
+	if _, err := check(context.Background()); err == nil {
+		t.Fatal("ping 失败时应返回错误")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRabbitMQHealthCheckDisconnected(t *testing.T) {
+	detail, err := RabbitMQHealthCheck(&utils.RabbitMQ{})(context.Background())
+	if err == nil {
+		t.Fatal("未连接时应返回错误")
+	}
+	if status, ok := detail.(utils.RabbitMQStatus); !ok || status.ConnectionOpen {
+		t.Fatalf("detail = %+v", detail)
+	}
+}
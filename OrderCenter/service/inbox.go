@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrMessageProcessed 消息已在收件箱中，说明是重复投递
+var ErrMessageProcessed = errors.New("消息已处理")
+
+// messageKey 消费端去重键，id 为空表示不去重（如 REST 调用）
+type messageKey struct {
+	queue string
+	id    string
+}
+
+type messageKeyCtx struct{}
+
+func withMessageKey(ctx context.Context, key messageKey) context.Context {
+	return context.WithValue(ctx, messageKeyCtx{}, key)
+}
+
+func messageKeyFrom(ctx context.Context) messageKey {
+	key, _ := ctx.Value(messageKeyCtx{}).(messageKey)
+	return key
+}
+
+// message_inbox.message_id 列的长度，AMQP 的 message_id 最长 255 字节
+const maxMessageIDLen = 191
+
+// MessageKey 消息的去重 ID：优先取 message_id，上游没有设置时取消息体的 SHA-256。
+// 超过列长度的 message_id 改为取它的 SHA-256，前缀与消息体的摘要区分。
+func MessageKey(d amqp.Delivery) string {
+	if d.MessageId != "" {
+		if len(d.MessageId) <= maxMessageIDLen {
+			return d.MessageId
+		}
+		sum := sha256.Sum256([]byte(d.MessageId))
+		return "message_id-sha256:" + hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(d.Body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// dedupHandler 把消息的去重键放入 ctx，订单变更时在同一事务中登记；重复消息按 ErrDuplicate 确认
+func dedupHandler(queue string, next utils.MessageHandler) utils.MessageHandler {
+	return func(ctx context.Context, d amqp.Delivery) error {
+		return duplicateAsAck(next(withMessageKey(ctx, messageKey{queue: queue, id: MessageKey(d)}), d))
+	}
+}
+
+// dedupBatchHandler 批量处理时为每条消息设置去重键
+func dedupBatchHandler(queue string, next utils.BatchHandler) utils.BatchHandler {
+	return func(ctx context.Context, items []utils.BatchItem) []error {
+		for i := range items {
+			items[i].Ctx = withMessageKey(items[i].Ctx, messageKey{queue: queue, id: MessageKey(items[i].Delivery)})
+		}
+		errs := next(ctx, items)
+		for i := range errs {
+			errs[i] = duplicateAsAck(errs[i])
+		}
+		return errs
+	}
+}
+
+func duplicateAsAck(err error) error {
+	if errors.Is(err, ErrMessageProcessed) {
+		return fmt.Errorf("%w: %v", utils.ErrDuplicate, err)
+	}
+	return err
+}
+
+// claimMessage 在事务中登记 ctx 中的消息，已处理过时返回 ErrMessageProcessed
+func (s *OrderService) claimMessage(ctx context.Context, tx *repository.OrderRepository) error {
+	keys := []messageKey{messageKeyFrom(ctx)}
+	processed, err := s.claimMessages(ctx, tx, keys, []int{0})
+	if err != nil {
+		return err
+	}
+	if processed[0] {
+		return ErrMessageProcessed
+	}
+	return nil
+}
+
+// claimMessages 在事务中登记 keys 中下标为 idx 的消息，返回其中已处理过的下标。
+// 同一批里重复的消息只有第一条算新消息。未配置有效期时不去重。
+func (s *OrderService) claimMessages(ctx context.Context, tx *repository.OrderRepository, keys []messageKey, idx []int) (map[int]bool, error) {
+	processed := make(map[int]bool)
+	if s.inboxTTL <= 0 {
+		return processed, nil
+	}
+
+	byQueue := make(map[string][]int)
+	for _, i := range idx {
+		if keys[i].id != "" {
+			byQueue[keys[i].queue] = append(byQueue[keys[i].queue], i)
+		}
+	}
+	now := time.Now()
+	for queue, positions := range byQueue {
+		ids := make([]string, len(positions))
+		for j, i := range positions {
+			ids[j] = keys[i].id
+		}
+		claimed, err := tx.ClaimMessages(ctx, queue, ids, now, s.inboxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("登记消息失败: %w", err)
+		}
+		for _, i := range positions {
+			if claimed[keys[i].id] {
+				delete(claimed, keys[i].id)
+			} else {
+				processed[i] = true
+			}
+		}
+	}
+	return processed, nil
+}
+
+// messageProcessed 事务外检查 ctx 中的消息是否已处理，用于分多个事务执行的操作提前跳过
+func (s *OrderService) messageProcessed(ctx context.Context) (bool, error) {
+	key := messageKeyFrom(ctx)
+	if s.inboxTTL <= 0 || key.id == "" {
+		return false, nil
+	}
+	processed, err := s.repo.MessageProcessed(ctx, key.queue, key.id, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("检查消息失败: %w", err)
+	}
+	return processed, nil
+}
+
+// InboxCleaner 定期删除过期的收件箱记录
+type InboxCleaner struct {
+	repo      *repository.OrderRepository
+	interval  time.Duration
+	batchSize int
+	logger    *slog.Logger
+}
+
+func NewInboxCleaner(repo *repository.OrderRepository, interval time.Duration, logger *slog.Logger) *InboxCleaner {
+	return &InboxCleaner{
+		repo:      repo,
+		interval:  interval,
+		batchSize: 1000,
+		logger:    logger.With("component", "inbox_cleaner"),
+	}
+}
+
+// Run 阻塞运行直到 ctx 取消
+func (c *InboxCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	c.logger.Info("收件箱清理已启动", "interval", c.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("收件箱清理退出")
+			return
+		case <-ticker.C:
+			c.clean(ctx)
+		}
+	}
+}
+
+// clean 分批删除，避免一次删除大量行长时间持锁
+func (c *InboxCleaner) clean(ctx context.Context) {
+	now := time.Now()
+	var total int64
+	for ctx.Err() == nil {
+		n, err := c.repo.DeleteExpiredMessages(ctx, now, c.batchSize)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "删除过期收件箱记录失败", logging.Err(err))
+			return
+		}
+		total += n
+		if n < int64(c.batchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		c.logger.Info("已删除过期收件箱记录", "count", total)
+	}
+}
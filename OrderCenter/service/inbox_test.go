@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/streadway/amqp"
+)
+
+func TestMessageKey(t *testing.T) {
+	long := strings.Repeat("m", 255)
+	tests := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     string
+	}{
+		{"message_id", amqp.Delivery{MessageId: "m-1", Body: []byte("a")}, "m-1"},
+		{"恰好列长度", amqp.Delivery{MessageId: long[:maxMessageIDLen]}, long[:maxMessageIDLen]},
+		{"消息体摘要", amqp.Delivery{Body: []byte("a")},
+			"sha256:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MessageKey(tt.delivery); got != tt.want {
+				t.Fatalf("MessageKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// 超长的 message_id 取摘要：长度不超过列宽，不同 ID 不冲突，同一 ID 结果稳定
+	a := MessageKey(amqp.Delivery{MessageId: long})
+	b := MessageKey(amqp.Delivery{MessageId: long[:254] + "n"})
+	if len(a) > maxMessageIDLen || !strings.HasPrefix(a, "message_id-sha256:") {
+		t.Fatalf("超长 message_id 的去重键 = %q", a)
+	}
+	if a == b || a != MessageKey(amqp.Delivery{MessageId: long, Body: []byte("x")}) {
+		t.Fatalf("去重键不稳定或冲突: %q, %q", a, b)
+	}
+}
+
+func TestHandleMessageBatchInbox(t *testing.T) {
+	srv, mock := newMockService(t, WithInboxTTL(time.Hour))
+	deliveries := []amqp.Delivery{
+		orderDelivery(t, "o-1", 1),
+		orderDelivery(t, "o-2", 1),
+		// 与第一条是同一条消息的重复投递
+		orderDelivery(t, "o-1", 1),
+	}
+	deliveries[0].MessageId, deliveries[1].MessageId, deliveries[2].MessageId = "m-1", "m-2", "m-1"
+	items := make([]utils.BatchItem, len(deliveries))
+	for i, d := range deliveries {
+		items[i] = batchItem(d)
+	}
+	handler := dedupBatchHandler("q", func(ctx context.Context, items []utils.BatchItem) []error {
+		return handleMessageBatch(ctx, items, srv)
+	})
+
+	mock.ExpectQuery("SELECT .*`order_data`").WillReturnRows(sqlmock.NewRows([]string{"order_id"}))
+	mock.ExpectBegin()
+	// m-1 首次登记，m-2 已被之前的投递处理过
+	mock.ExpectExec("INSERT INTO message_inbox").WithArgs("q", "m-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO message_inbox").WithArgs("q", "m-2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	errs := handler(context.Background(), items)
+	checkBatchErrs(t, errs, []error{nil, utils.ErrDuplicate, utils.ErrDuplicate})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
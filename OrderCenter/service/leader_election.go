@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/repository"
+)
+
+// LeaderJob 只在持有领导权时运行的后台任务，失去领导权时 ctx 会被取消。
+// ctx 带有本任期的 fencing token（repository.WithFence），仓库的写操作据此拒绝旧主节点的写入。
+type LeaderJob func(ctx context.Context)
+
+// LeaderStatus 当前实例的领导权状态
+type LeaderStatus struct {
+	Lease        string     `json:"lease"`
+	InstanceID   string     `json:"instance_id"`
+	IsLeader     bool       `json:"is_leader"`
+	Leader       string     `json:"leader"`
+	FencingToken int64      `json:"fencing_token"`
+	LeaderSince  *time.Time `json:"leader_since,omitempty"`
+	Jobs         []string   `json:"jobs"`
+}
+
+type leaderJob struct {
+	name string
+	run  LeaderJob
+}
+
+// LeaderElector 基于 MySQL 租约的主节点选举。
+// 持有租约的实例运行所有注册的单例任务，续约失败超过租约时长后主动退位。
+type LeaderElector struct {
+	repo          *repository.LeaseRepository
+	lease         string
+	instanceID    string
+	ttl           time.Duration
+	renewInterval time.Duration
+	logger        *slog.Logger
+
+	mu          sync.RWMutex
+	jobs        []leaderJob
+	isLeader    bool
+	leader      string
+	token       int64
+	leaderSince time.Time
+	lastRenew   time.Time
+
+	cancelJobs context.CancelFunc
+	jobsWG     sync.WaitGroup
+}
+
+func NewLeaderElector(repo *repository.LeaseRepository, lease, instanceID string, ttl, renewInterval time.Duration, logger *slog.Logger) *LeaderElector {
+	return &LeaderElector{
+		repo:          repo,
+		lease:         lease,
+		instanceID:    instanceID,
+		ttl:           ttl,
+		renewInterval: renewInterval,
+		logger:        logger.With("component", "leader_elector", "lease", lease, "instance", instanceID),
+	}
+}
+
+// Register 注册单例任务，需在 Run 之前调用
+func (e *LeaderElector) Register(name string, job LeaderJob) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobs = append(e.jobs, leaderJob{name: name, run: job})
+}
+
+// Run 阻塞运行选举循环直到 ctx 取消，退出时停止任务并释放租约
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	e.logger.Info("主节点选举已启动")
+
+	for {
+		e.heartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.stepDown()
+			// ctx 已取消，释放租约单独给一个短超时
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			err := e.repo.Release(releaseCtx, e.lease, e.instanceID)
+			cancel()
+			if err != nil {
+				e.logger.Error("释放租约失败", logging.Err(err))
+			}
+			e.logger.Info("主节点选举退出")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *LeaderElector) heartbeat(ctx context.Context) {
+	// 数据库卡住时不能一直阻塞，否则无法按时退位
+	hbCtx, cancel := context.WithTimeout(ctx, e.renewInterval)
+	defer cancel()
+	lease, held, err := e.repo.TryAcquire(hbCtx, e.lease, e.instanceID, e.ttl)
+	if err != nil {
+		e.logger.Error("续约失败", logging.Err(err))
+		// 无法确认租约是否还在，超过租约时长后必须退位
+		e.mu.RLock()
+		expired := e.isLeader && time.Since(e.lastRenew) >= e.ttl
+		e.mu.RUnlock()
+		if expired {
+			e.stepDown()
+		}
+		return
+	}
+
+	e.mu.Lock()
+	e.leader = lease.Holder
+	if held {
+		e.lastRenew = time.Now()
+	}
+	wasLeader := e.isLeader
+	e.mu.Unlock()
+
+	switch {
+	case held && !wasLeader:
+		e.becomeLeader(ctx, lease.FencingToken)
+	case !held && wasLeader:
+		e.stepDown()
+	}
+}
+
+func (e *LeaderElector) becomeLeader(ctx context.Context, token int64) {
+	jobCtx, cancel := context.WithCancel(repository.WithFence(ctx, e.lease, token))
+
+	e.mu.Lock()
+	e.isLeader = true
+	e.token = token
+	e.leaderSince = time.Now()
+	e.cancelJobs = cancel
+	jobs := e.jobs
+	e.mu.Unlock()
+
+	e.logger.Info("已获得领导权", "fencing_token", token, "jobs", len(jobs))
+	for _, job := range jobs {
+		e.jobsWG.Add(1)
+		go func(job leaderJob) {
+			defer e.jobsWG.Done()
+			job.run(jobCtx)
+		}(job)
+	}
+}
+
+// stepDown 取消所有任务并等待退出
+func (e *LeaderElector) stepDown() {
+	e.mu.Lock()
+	if !e.isLeader {
+		e.mu.Unlock()
+		return
+	}
+	e.isLeader = false
+	cancel := e.cancelJobs
+	e.cancelJobs = nil
+	e.mu.Unlock()
+
+	cancel()
+	e.jobsWG.Wait()
+	e.logger.Info("已失去领导权")
+}
+
+// IsLeader 当前实例是否持有领导权
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader
+}
+
+// Status 返回领导权状态，用于状态接口
+func (e *LeaderElector) Status() LeaderStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	jobs := make([]string, 0, len(e.jobs))
+	for _, job := range e.jobs {
+		jobs = append(jobs, job.name)
+	}
+	status := LeaderStatus{
+		Lease:      e.lease,
+		InstanceID: e.instanceID,
+		IsLeader:   e.isLeader,
+		Leader:     e.leader,
+		Jobs:       jobs,
+	}
+	if e.isLeader {
+		status.FencingToken = e.token
+		since := e.leaderSince
+		status.LeaderSince = &since
+	}
+	return status
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newMockElector(t *testing.T, ttl time.Duration) (*LeaderElector, sqlmock.Sqlmock) {
+	t.Helper()
+	provider, mock := newMockProvider(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewLeaderElector(repository.NewLeaseRepository(provider), "jobs", "a", ttl, time.Second, logger), mock
+}
+
+// expectHeartbeat 一次续约：updated 为条件更新影响的行数，holder 和 token 为读回的租约
+func expectHeartbeat(mock sqlmock.Sqlmock, updated int64, holder string, token int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `leader_lease`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE leader_lease").WillReturnResult(sqlmock.NewResult(0, updated))
+	mock.ExpectQuery("SELECT \\* FROM `leader_lease`").WillReturnRows(
+		sqlmock.NewRows([]string{"name", "holder", "fencing_token", "expires_at", "updated_at"}).
+			AddRow("jobs", holder, token, time.Now().Add(time.Minute), time.Now()))
+}
+
+// 接管租约后启动任务，租约被其他实例拿走后取消任务
+func TestLeaderElectorTakeoverAndLoss(t *testing.T) {
+	e, mock := newMockElector(t, 15*time.Second)
+	started := make(chan context.Context, 1)
+	e.Register("job", func(ctx context.Context) {
+		started <- ctx
+		<-ctx.Done()
+	})
+
+	// 上一任主节点 b 的租约已过期，fencing_token 由 1 加到 2
+	expectHeartbeat(mock, 1, "a", 2)
+	e.heartbeat(context.Background())
+	status := e.Status()
+	if !status.IsLeader || status.Leader != "a" || status.FencingToken != 2 {
+		t.Fatalf("接管后 status = %+v", status)
+	}
+	var jobCtx context.Context
+	select {
+	case jobCtx = <-started:
+	case <-time.After(time.Second):
+		t.Fatal("获得领导权后任务未启动")
+	}
+
+	// 续约：token 不变，任务继续运行
+	expectHeartbeat(mock, 1, "a", 2)
+	e.heartbeat(context.Background())
+	if !e.IsLeader() || jobCtx.Err() != nil {
+		t.Fatal("续约后不应退位")
+	}
+
+	// b 在租约过期后接管，a 的条件更新不生效
+	expectHeartbeat(mock, 0, "b", 3)
+	e.heartbeat(context.Background())
+	status = e.Status()
+	if status.IsLeader || status.Leader != "b" || status.FencingToken != 0 {
+		t.Fatalf("失去租约后 status = %+v", status)
+	}
+	// stepDown 等待任务退出，返回时任务的 ctx 已取消
+	if jobCtx.Err() == nil {
+		t.Fatal("失去领导权后任务的 ctx 未取消")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 续约失败时在租约时长内保持领导权，超过租约时长后退位
+func TestLeaderElectorRenewFailure(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	e, mock := newMockElector(t, ttl)
+	e.Register("job", func(ctx context.Context) { <-ctx.Done() })
+
+	expectHeartbeat(mock, 1, "a", 1)
+	e.heartbeat(context.Background())
+	if !e.IsLeader() {
+		t.Fatal("未获得领导权")
+	}
+
+	mock.ExpectBegin().WillReturnError(errors.New("数据库不可用"))
+	e.heartbeat(context.Background())
+	if !e.IsLeader() {
+		t.Fatal("租约未到期前不应退位")
+	}
+
+	time.Sleep(ttl)
+	mock.ExpectBegin().WillReturnError(errors.New("数据库不可用"))
+	e.heartbeat(context.Background())
+	if e.IsLeader() {
+		t.Fatal("续约失败超过租约时长后应退位")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
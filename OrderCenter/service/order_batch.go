@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+)
+
+// 批量操作的事务模式
+const (
+	// 任一条失败则整批不写入
+	BatchModeAtomic = "atomic"
+	// 跳过失败的条目，其余照常写入
+	BatchModeBestEffort = "best_effort"
+)
+
+// 单次批量操作的最大条数
+const maxBatchItems = 1000
+
+// ErrInvalidBatch 批量请求本身无效，接口返回 400
+var ErrInvalidBatch = errors.New("批量请求无效")
+
+// 整批回滚时未出错条目的说明
+const batchSkippedMessage = "未执行：同批次存在失败条目，整批已回滚"
+
+// BatchItemResult 单条的处理结果
+type BatchItemResult struct {
+	OrderID string `json:"order_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchResult 批量操作结果，Results 与请求中的条目一一对应
+type BatchResult struct {
+	Mode      string `json:"mode"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	// atomic 模式下整批未写入
+	RolledBack bool              `json:"rolled_back"`
+	Results    []BatchItemResult `json:"results"`
+}
+
+func newBatchResult(mode string, ids []string) *BatchResult {
+	res := &BatchResult{Mode: mode, Results: make([]BatchItemResult, len(ids))}
+	for i, id := range ids {
+		res.Results[i].OrderID = id
+	}
+	return res
+}
+
+func (r *BatchResult) fail(i int, msg string) {
+	r.Results[i].Success = false
+	r.Results[i].Error = msg
+}
+
+func (r *BatchResult) succeed(i int) {
+	r.Results[i].Success = true
+	r.Results[i].Error = ""
+}
+
+// rollback 整批未写入：未出错的条目标记为未执行
+func (r *BatchResult) rollback(pending []int, msg string) {
+	r.RolledBack = true
+	for _, i := range pending {
+		r.fail(i, msg)
+	}
+}
+
+func (r *BatchResult) count() *BatchResult {
+	r.Succeeded, r.Failed = 0, 0
+	for _, item := range r.Results {
+		if item.Success {
+			r.Succeeded++
+		} else {
+			r.Failed++
+		}
+	}
+	return r
+}
+
+func validateBatch(mode string, n int) error {
+	if mode != BatchModeAtomic && mode != BatchModeBestEffort {
+		return fmt.Errorf("%w: 不支持的模式 %q", ErrInvalidBatch, mode)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: 条目为空", ErrInvalidBatch)
+	}
+	if n > maxBatchItems {
+		return fmt.Errorf("%w: 单次最多 %d 条", ErrInvalidBatch, maxBatchItems)
+	}
+	return nil
+}
+
+// checkBatchIDs 校验 ID 非空且不重复，want 为 true 时要求订单已存在且未进入终态，false 时要求不存在。
+// 返回通过校验的条目下标。
+func (s *OrderService) checkBatchIDs(ctx context.Context, res *BatchResult, ids []string, want bool) ([]int, error) {
+	statuses, err := s.repo.FindStatuses(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("检查订单失败: %w", err)
+	}
+
+	pending := make([]int, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		status, exists := statuses[id]
+		switch {
+		case id == "":
+			res.fail(i, "order_id 不能为空")
+		case seen[id]:
+			res.fail(i, "order_id 在请求中重复")
+		case exists && !want:
+			res.fail(i, "订单已存在，order_id 重复")
+		case !exists && want:
+			res.fail(i, "订单不存在")
+		case want && isTerminal(status):
+			res.fail(i, fmt.Sprintf("订单已处于终态 %s", status))
+		default:
+			pending = append(pending, i)
+		}
+		seen[id] = true
+	}
+	return pending, nil
+}
+
+// CreateOrders 批量创建订单，使用多行插入，推送事件在同一事务中写入。
+// best_effort 模式下多行插入失败时逐条重试，找出具体失败的条目。
+func (s *OrderService) CreateOrders(ctx context.Context, orders []model.OrderData, mode string) (*BatchResult, error) {
+	if err := validateBatch(mode, len(orders)); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderID
+	}
+	res := newBatchResult(mode, ids)
+
+	pending, err := s.checkBatchIDs(ctx, res, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	if mode == BatchModeAtomic && len(pending) < len(orders) {
+		res.rollback(pending, batchSkippedMessage)
+		return res.count(), nil
+	}
+	if len(pending) == 0 {
+		return res.count(), nil
+	}
+
+	now := time.Now()
+	batch := make([]*model.OrderData, len(pending))
+	for j, i := range pending {
+		order := &orders[i]
+		s.prepareNewOrder(order, now)
+		batch[j] = order
+	}
+
+	// 多行插入放在同一事务中，失败时不会留下部分写入的行
+	err = s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		if err := tx.CreateBatch(ctx, batch); err != nil {
+			return err
+		}
+		return s.events.WriteCreated(ctx, tx, batch, now)
+	})
+	if err == nil {
+		for _, i := range pending {
+			res.succeed(i)
+		}
+		return res.count(), nil
+	}
+
+	if mode == BatchModeAtomic {
+		res.rollback(pending, fmt.Sprintf("批量写入失败，整批已回滚: %v", err))
+		return res.count(), nil
+	}
+	s.logger.WarnContext(ctx, "批量写入失败，改为逐条写入", "count", len(batch), logging.Err(err))
+	for _, i := range pending {
+		if err := s.insertOrder(ctx, &orders[i]); err != nil {
+			res.fail(i, err.Error())
+			continue
+		}
+		res.succeed(i)
+	}
+	return res.count(), nil
+}
+
+// WithdrawOrders 批量撤单，订单置为 withdrawn 并保留记录。
+// 使用 IN 条件一次完成，历史和撤单事件在同一事务中写入；best_effort 模式下失败时逐条重试。
+func (s *OrderService) WithdrawOrders(ctx context.Context, ids []string, mode string) (*BatchResult, error) {
+	if err := validateBatch(mode, len(ids)); err != nil {
+		return nil, err
+	}
+	res := newBatchResult(mode, ids)
+
+	pending, err := s.checkBatchIDs(ctx, res, ids, true)
+	if err != nil {
+		return nil, err
+	}
+	if mode == BatchModeAtomic && len(pending) < len(ids) {
+		res.rollback(pending, batchSkippedMessage)
+		return res.count(), nil
+	}
+	if len(pending) == 0 {
+		return res.count(), nil
+	}
+
+	targets := make([]string, len(pending))
+	for j, i := range pending {
+		targets[j] = ids[i]
+	}
+	_, err = s.lockAndWithdraw(ctx, targets)
+	if err == nil {
+		for _, i := range pending {
+			res.succeed(i)
+		}
+		return res.count(), nil
+	}
+
+	if mode == BatchModeAtomic {
+		res.rollback(pending, fmt.Sprintf("批量撤单失败，整批已回滚: %v", err))
+		return res.count(), nil
+	}
+	s.logger.WarnContext(ctx, "批量撤单失败，改为逐条撤单", "count", len(targets), logging.Err(err))
+	for _, i := range pending {
+		if _, err := s.lockAndWithdraw(ctx, ids[i:i+1]); err != nil {
+			res.fail(i, err.Error())
+			continue
+		}
+		res.succeed(i)
+	}
+	return res.count(), nil
+}
+
+// lockAndWithdraw 在一个事务中锁定并撤掉指定订单，同时写入撤单事件，返回变更前的快照。
+// 已进入终态的订单不再撤单，不会出现在结果中。
+func (s *OrderService) lockAndWithdraw(ctx context.Context, ids []string) ([]model.OrderData, error) {
+	var befores []model.OrderData
+	err := s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		if err := s.claimMessage(ctx, tx); err != nil {
+			return err
+		}
+		orders, err := tx.LockOpenByIDs(ctx, ids)
+		if err != nil || len(orders) == 0 {
+			return err
+		}
+		if err := withdrawLocked(ctx, tx, orders); err != nil {
+			return err
+		}
+		if err := s.events.WriteLifecycle(ctx, tx, EventOrderWithdrawn, lifecycleActionWithdrawn, withdrawnChanges(orders), time.Now()); err != nil {
+			return err
+		}
+		befores = orders
+		return nil
+	})
+	return befores, err
+}
+
+// errBatchChanged 校验之后订单被并发撤单或过期
+var errBatchChanged = errors.New("部分订单已被并发撤单或过期")
+
+// UpdateOrders 批量更新订单，每条按 order_id 更新非零字段，规则同 UpdateOrder。
+// 一个事务内锁定全部订单后逐行更新，历史和 order_updated 事件在同一事务中写入；
+// best_effort 模式下失败时逐条重试。
+func (s *OrderService) UpdateOrders(ctx context.Context, orders []model.OrderData, mode string) (*BatchResult, error) {
+	if err := validateBatch(mode, len(orders)); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderID
+	}
+	res := newBatchResult(mode, ids)
+
+	checked, err := s.checkBatchIDs(ctx, res, ids, true)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]int, 0, len(checked))
+	for _, i := range checked {
+		if err := validateUpdate(&orders[i]); err != nil {
+			res.fail(i, err.Error())
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if mode == BatchModeAtomic && len(pending) < len(orders) {
+		res.rollback(pending, batchSkippedMessage)
+		return res.count(), nil
+	}
+	if len(pending) == 0 {
+		return res.count(), nil
+	}
+
+	err = s.lockAndUpdate(ctx, orders, pending)
+	if err == nil {
+		for _, i := range pending {
+			res.succeed(i)
+		}
+		return res.count(), nil
+	}
+
+	if mode == BatchModeAtomic {
+		res.rollback(pending, fmt.Sprintf("批量更新失败，整批已回滚: %v", err))
+		return res.count(), nil
+	}
+	s.logger.WarnContext(ctx, "批量更新失败，改为逐条更新", "count", len(pending), logging.Err(err))
+	for _, i := range pending {
+		if err := s.UpdateOrder(ctx, ids[i], &orders[i]); err != nil {
+			res.fail(i, err.Error())
+			continue
+		}
+		res.succeed(i)
+	}
+	return res.count(), nil
+}
+
+// lockAndUpdate 在一个事务中锁定并更新 orders 中下标为 idx 的订单，同时写入更新事件。
+// 任一订单已不能更新时整个事务回滚。
+func (s *OrderService) lockAndUpdate(ctx context.Context, orders []model.OrderData, idx []int) error {
+	ids := make([]string, len(idx))
+	for j, i := range idx {
+		ids[j] = orders[i].OrderID
+	}
+	return s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		locked, err := tx.LockOpenByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		if len(locked) < len(ids) {
+			return errBatchChanged
+		}
+		byID := make(map[string]*model.OrderData, len(locked))
+		for i := range locked {
+			byID[locked[i].OrderID] = &locked[i]
+		}
+		changes := make([]LifecycleChange, len(idx))
+		for j, i := range idx {
+			before := byID[orders[i].OrderID]
+			after, err := updateLocked(ctx, tx, before, &orders[i])
+			if err != nil {
+				return err
+			}
+			changes[j] = LifecycleChange{Before: before, After: after}
+		}
+		return s.events.WriteLifecycle(ctx, tx, EventOrderUpdated, lifecycleActionUpdated, changes, time.Now())
+	})
+}
+
+func isTerminal(status string) bool {
+	for _, s := range model.TerminalStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
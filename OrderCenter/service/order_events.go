@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"trade-solution/ordercenter/config"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/pb"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/tracing"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 订单事件类型
+const (
+	EventOrderCreated   = "order_created"
+	EventOrderUpdated   = "order_updated"
+	EventOrderWithdrawn = "order_withdrawn"
+	EventOrderExpired   = "order_expired"
+)
+
+// 生命周期事件 routingKey 的最后一段
+const (
+	lifecycleActionUpdated   = "updated"
+	lifecycleActionWithdrawn = "withdrawn"
+	lifecycleActionExpired   = "expired"
+)
+
+const pushExchange = config.OrderEventExchange
+
+var errNoPublisher = errors.New("未配置 RabbitMQ，无法推送事件")
+
+// EventSchemaVersion 当前事件信封的版本，字段有不兼容变更时递增
+const EventSchemaVersion = 1
+
+// OrderEventSchema 事件信封的 JSON Schema，通过 /schemas 接口对外提供。
+// 只描述 JSON 编码；msgpack 编码字段名相同，但时间字段为 msgpack timestamp；
+// protobuf 编码见 pb/order.proto 中的 OrderEvent。
+//
+//go:embed schemas/order_event.v1.json
+var OrderEventSchema []byte
+
+// EventEnvelope 推送到 order_event_exchange 的事件信封
+type EventEnvelope struct {
+	EventID       string       `json:"event_id"`
+	EventType     string       `json:"event_type"`
+	SchemaVersion int          `json:"schema_version"`
+	OccurredAt    time.Time    `json:"occurred_at"`
+	Producer      string       `json:"producer"`
+	OrderID       string       `json:"order_id"`
+	Payload       EventPayload `json:"payload"`
+}
+
+// EventPayload 事件内容：Order 为事件发生后数据库中的订单；
+// Before 为变更前的订单，新建订单时为空
+type EventPayload struct {
+	Order  *EventOrder `json:"order,omitempty"`
+	Before *EventOrder `json:"before,omitempty"`
+}
+
+// EventOrder 事件中对外公开的订单字段。
+// 不包含 Metadata：其中是上游原始消息和调度暂存的状态，属于内部数据。
+type EventOrder struct {
+	OrderID        string     `json:"order_id"`
+	Status         string     `json:"status"`
+	EventTimestamp int64      `json:"event_timestamp"`
+	StrategyID     int64      `json:"strategy_id"`
+	UserID         string     `json:"user_id"`
+	BscPublicKey   string     `json:"bsc_public_key"`
+	SolPublicKey   string     `json:"sol_public_key"`
+	TokenAddress   string     `json:"token_address"`
+	ChainIndex     int        `json:"chain_index"`
+	EventType      string     `json:"event_type"`
+	ActivateAt     *time.Time `json:"activate_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func newEventOrder(o *model.OrderData) *EventOrder {
+	if o == nil {
+		return nil
+	}
+	return &EventOrder{
+		OrderID:        o.OrderID,
+		Status:         o.Status,
+		EventTimestamp: o.EventTimestamp,
+		StrategyID:     o.StrategyID,
+		UserID:         o.UserID,
+		BscPublicKey:   o.BscPublicKey,
+		SolPublicKey:   o.SolPublicKey,
+		TokenAddress:   o.TokenAddress,
+		ChainIndex:     o.ChainIndex,
+		EventType:      o.EventType,
+		ActivateAt:     o.ActivateAt,
+		ExpiresAt:      o.ExpiresAt,
+		CreatedAt:      o.CreatedAt,
+		UpdatedAt:      o.UpdatedAt,
+	}
+}
+
+// ToProto 转换为 ordercenter.v1.OrderEvent，交换机编码为 protobuf 时使用
+func (e EventEnvelope) ToProto() proto.Message {
+	return &pb.OrderEvent{
+		EventId:       e.EventID,
+		EventType:     e.EventType,
+		SchemaVersion: int32(e.SchemaVersion),
+		OccurredAt:    timestamppb.New(e.OccurredAt),
+		Producer:      e.Producer,
+		OrderId:       e.OrderID,
+		Payload: &pb.EventPayload{
+			Order:  e.Payload.Order.toProto(),
+			Before: e.Payload.Before.toProto(),
+		},
+	}
+}
+
+func (o *EventOrder) toProto() *pb.EventOrder {
+	if o == nil {
+		return nil
+	}
+	return &pb.EventOrder{
+		OrderId:        o.OrderID,
+		Status:         o.Status,
+		EventTimestamp: o.EventTimestamp,
+		StrategyId:     o.StrategyID,
+		UserId:         o.UserID,
+		BscPublicKey:   o.BscPublicKey,
+		SolPublicKey:   o.SolPublicKey,
+		TokenAddress:   o.TokenAddress,
+		ChainIndex:     int64(o.ChainIndex),
+		EventType:      o.EventType,
+		ActivateAt:     protoTime(o.ActivateAt),
+		ExpiresAt:      protoTime(o.ExpiresAt),
+		CreatedAt:      timestamppb.New(o.CreatedAt),
+		UpdatedAt:      timestamppb.New(o.UpdatedAt),
+	}
+}
+
+func protoTime(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// OrderEvent 旧格式的生命周期事件，仅在兼容模式下推送
+type OrderEvent struct {
+	EventType  string           `json:"event_type"`
+	OrderID    string           `json:"order_id"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Before     *model.OrderData `json:"before,omitempty"`
+	After      *model.OrderData `json:"after,omitempty"`
+}
+
+// LifecycleRoutingKey 生命周期事件的 routingKey：order.<chain>.<event_type>.<action>。
+// 比新订单多一段，按 order.*.* 订阅的下游不会收到生命周期事件。
+func LifecycleRoutingKey(chainIndex int, eventType, action string) string {
+	return fmt.Sprintf("%s.%s", PushRoutingKey(chainIndex, eventType), action)
+}
+
+// EventPublisher 统一编码订单事件。
+// 事件在订单变更的事务中写入发件箱（Write* 方法），由 OutboxRelay 推送到 order_event_exchange。
+// legacy 为 true 时推送迁移前的格式：新订单推送上游原始消息，生命周期事件推送 OrderEvent。
+type EventPublisher struct {
+	rmq      *utils.RabbitMQ
+	producer string
+	legacy   bool
+}
+
+func NewEventPublisher(rmq *utils.RabbitMQ, producer string, legacy bool) *EventPublisher {
+	return &EventPublisher{rmq: rmq, producer: producer, legacy: legacy}
+}
+
+// Validate 检查事件格式与交换机编码是否匹配：迁移前的格式没有对应的 .proto，不能按 protobuf 推送
+func (p *EventPublisher) Validate() error {
+	if p.legacy && strings.HasPrefix(p.rmq.ExchangeCodec(pushExchange).ContentType(), utils.ContentTypeProtobuf) {
+		return fmt.Errorf("%s 使用 protobuf 编码时不支持旧格式事件", pushExchange)
+	}
+	return nil
+}
+
+// WriteCreated 在事务中为新生效的订单写入 order_created 事件，orders 为入库后的订单。
+// 调度中的订单跳过，由调度器生效时写入。
+func (p *EventPublisher) WriteCreated(ctx context.Context, tx *repository.OrderRepository, orders []*model.OrderData, now time.Time) error {
+	return p.WriteCreatedEach(ctx, tx, orders, nil, now)
+}
+
+// WriteCreatedEach 同 WriteCreated，orderCtxs 与 orders 一一对应，
+// 每条事件带上对应消息的请求 ID 和 trace；为空时都使用 ctx。
+func (p *EventPublisher) WriteCreatedEach(ctx context.Context, tx *repository.OrderRepository, orders []*model.OrderData, orderCtxs []context.Context, now time.Time) error {
+	if p == nil || p.rmq == nil {
+		return errNoPublisher
+	}
+	events := make([]*model.EventOutbox, 0, len(orders))
+	for i, order := range orders {
+		if order.Status == model.OrderStatusScheduled {
+			continue
+		}
+		eventCtx := ctx
+		if orderCtxs != nil {
+			eventCtx = orderCtxs[i]
+		}
+		eventID := uuid.NewString()
+		event, err := p.outboxEvent(eventCtx, eventID, EventOrderCreated, order.OrderID,
+			PushRoutingKey(order.ChainIndex, order.EventType), p.createdBody(eventID, order, now))
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	if err := tx.CreateOutboxEvents(ctx, events); err != nil {
+		return fmt.Errorf("写入 %s 事件失败: %w", EventOrderCreated, err)
+	}
+	return nil
+}
+
+func (p *EventPublisher) createdBody(eventID string, order *model.OrderData, now time.Time) interface{} {
+	if p.legacy {
+		// 队列写入的订单推送原始消息，早期没有保存原始消息的订单推送订单本身
+		if msg, ok := order.Metadata["order"]; ok {
+			return msg
+		}
+		return order
+	}
+	return p.envelope(eventID, EventOrderCreated, order.OrderID, order, nil, now)
+}
+
+// LifecycleChange 一个订单的变更：Before 为变更前，After 为变更后
+type LifecycleChange struct {
+	Before *model.OrderData
+	After  *model.OrderData
+}
+
+// WriteLifecycle 在事务中把同一类生命周期事件写入发件箱，每个变更一条
+func (p *EventPublisher) WriteLifecycle(ctx context.Context, tx *repository.OrderRepository, eventType, action string, changes []LifecycleChange, now time.Time) error {
+	if p == nil || p.rmq == nil {
+		return errNoPublisher
+	}
+	events := make([]*model.EventOutbox, len(changes))
+	for i, c := range changes {
+		ref := c.Before
+		if ref == nil {
+			ref = c.After
+		}
+		eventID := uuid.NewString()
+		body := p.lifecycleBody(eventID, eventType, c.Before, c.After, now)
+		event, err := p.outboxEvent(ctx, eventID, eventType, ref.OrderID, LifecycleRoutingKey(ref.ChainIndex, ref.EventType, action), body)
+		if err != nil {
+			return err
+		}
+		events[i] = event
+	}
+	if err := tx.CreateOutboxEvents(ctx, events); err != nil {
+		return fmt.Errorf("写入 %s 事件失败: %w", eventType, err)
+	}
+	return nil
+}
+
+func (p *EventPublisher) lifecycleBody(eventID, eventType string, before, after *model.OrderData, now time.Time) interface{} {
+	ref := before
+	if ref == nil {
+		ref = after
+	}
+	if p.legacy {
+		return OrderEvent{
+			EventType:  eventType,
+			OrderID:    ref.OrderID,
+			OccurredAt: now,
+			Before:     before,
+			After:      after,
+		}
+	}
+	return p.envelope(eventID, eventType, ref.OrderID, after, before, now)
+}
+
+// outboxEvent 按 order_event_exchange 的编码序列化事件，带上当前的请求 ID 和 trace 上下文
+func (p *EventPublisher) outboxEvent(ctx context.Context, eventID, eventType, orderID, routingKey string, body interface{}) (*model.EventOutbox, error) {
+	codec := p.rmq.ExchangeCodec(pushExchange)
+	data, err := codec.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("按 %s 编码 %s 事件失败: %w", codec.ContentType(), eventType, err)
+	}
+	headers := amqp.Table{}
+	tracing.InjectAMQP(ctx, headers)
+	return &model.EventOutbox{
+		EventID:       eventID,
+		EventType:     eventType,
+		OrderID:       orderID,
+		Exchange:      pushExchange,
+		RoutingKey:    routingKey,
+		ContentType:   codec.ContentType(),
+		Body:          data,
+		CorrelationID: logging.RequestID(ctx),
+		Headers:       model.JSONB(headers),
+	}, nil
+}
+
+func (p *EventPublisher) envelope(eventID, eventType, orderID string, order, before *model.OrderData, now time.Time) EventEnvelope {
+	return EventEnvelope{
+		EventID:       eventID,
+		EventType:     eventType,
+		SchemaVersion: EventSchemaVersion,
+		OccurredAt:    now.UTC(),
+		Producer:      p.producer,
+		OrderID:       orderID,
+		Payload:       EventPayload{Order: newEventOrder(order), Before: newEventOrder(before)},
+	}
+}
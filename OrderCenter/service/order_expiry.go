@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+)
+
+// OrderExpirySweeper 定期把超过 expires_at 的订单置为 expired，
+// 在同一事务中记录历史并写入过期事件，由 OutboxRelay 推送。
+// 状态变更使用 CAS，多个实例同时清理时每个订单只会被处理一次。
+type OrderExpirySweeper struct {
+	repo      *repository.OrderRepository
+	events    *EventPublisher
+	interval  time.Duration
+	batchSize int
+	logger    *slog.Logger
+}
+
+func NewOrderExpirySweeper(repo *repository.OrderRepository, events *EventPublisher, interval time.Duration, logger *slog.Logger) *OrderExpirySweeper {
+	return &OrderExpirySweeper{
+		repo:      repo,
+		events:    events,
+		interval:  interval,
+		batchSize: 200,
+		logger:    logger.With("component", "order_expiry_sweeper"),
+	}
+}
+
+// Run 阻塞运行直到 ctx 取消
+func (s *OrderExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	s.logger.Info("订单过期清理已启动", "interval", s.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("订单过期清理退出")
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *OrderExpirySweeper) sweep(ctx context.Context) {
+	now := time.Now()
+	orders, err := s.repo.FindExpired(ctx, now, s.batchSize)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "查询过期订单失败", logging.Err(err))
+		return
+	}
+	for i := range orders {
+		orderCtx := logging.WithOrderID(logging.WithRequestID(ctx, logging.NewRequestID()), orders[i].OrderID)
+		if err := s.expire(orderCtx, &orders[i], now); err != nil {
+			s.logger.ErrorContext(orderCtx, "订单过期处理失败", logging.Err(err))
+		}
+	}
+}
+
+func (s *OrderExpirySweeper) expire(ctx context.Context, order *model.OrderData, now time.Time) error {
+	claimed := false
+	err := s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		ok, err := tx.CompareAndSetStatus(ctx, order.OrderID, order.Status, model.OrderStatusExpired)
+		if err != nil || !ok {
+			return err
+		}
+		claimed = true
+		err = tx.CreateHistory(ctx, &model.OrderHistory{
+			OrderID:    order.OrderID,
+			Action:     model.HistoryActionExpire,
+			FromStatus: order.Status,
+			ToStatus:   model.OrderStatusExpired,
+			Snapshot:   model.JSONB{"order": order},
+		})
+		if err != nil {
+			return err
+		}
+		after := *order
+		after.Status = model.OrderStatusExpired
+		return s.events.WriteLifecycle(ctx, tx, EventOrderExpired, lifecycleActionExpired, []LifecycleChange{{Before: order, After: &after}}, now)
+	})
+	if err != nil {
+		return fmt.Errorf("更新过期状态失败: %w", err)
+	}
+	if !claimed {
+		// 已被其他实例处理或状态已变化
+		return nil
+	}
+
+	s.logger.InfoContext(ctx, "订单已过期", "from_status", order.Status)
+	return nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/pb"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+	"google.golang.org/protobuf/proto"
+)
+
+// SchemaVersionField 上游消息的 schema 版本，可放在消息头或消息体顶层，都没有时按 1 处理
+const SchemaVersionField = "schema_version"
+
+// ErrUnknownSchemaVersion 没有对应版本的解码器，消息进入死信队列
+var ErrUnknownSchemaVersion = errors.New("不支持的消息 schema 版本")
+
+// ingestDecoder 把某个版本的上游订单消息解码并升级为当前的 OrderData。
+// 消息体按 content_type 对应的 codec 解码；strict 为 true 时消息中出现未知字段直接拒绝，避免上游改名后写入零值。
+type ingestDecoder func(codec utils.Codec, body []byte, strict bool) (*model.OrderData, error)
+
+// ingestDecoders 按 schema 版本注册的解码器，上游升级格式时在这里新增版本
+var ingestDecoders = map[int]ingestDecoder{
+	1: decodeOrderV1,
+}
+
+// orderMessageV1 v1 格式：common 库的 models.Order，加上可选的调度字段和版本号
+type orderMessageV1 struct {
+	models.Order
+	orderSchedule
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+// FromProto 由 ordercenter.v1.OrderMessage 填充，content_type 为 protobuf 时使用
+func (m *orderMessageV1) FromProto(msg proto.Message) error {
+	pm, ok := msg.(*pb.OrderMessage)
+	if !ok {
+		return fmt.Errorf("v1 订单消息不支持 %s", msg.ProtoReflect().Descriptor().FullName())
+	}
+	info, strategy, chain := pm.GetOrderInfo(), pm.GetStrategyInfo(), pm.GetChainInfo()
+	user := strategy.GetUserInfo()
+	m.Order = models.Order{
+		OrderInfo: models.OrderInfo{
+			OrderID:        info.GetOrderId(),
+			Status:         info.GetStatus(),
+			EventTimestamp: info.GetEventTimestamp(),
+			EventType:      info.GetEventType(),
+		},
+		StrategyInfo: models.StrategyInfo{
+			StrategyId: int(strategy.GetStrategyId()),
+			UserInfo: models.UserInfo{
+				UserId:       user.GetUserId(),
+				BscPublicKey: user.GetBscPublicKey(),
+				SolPublicKey: user.GetSolPublicKey(),
+			},
+		},
+		ChainInfo: models.ChainInfo{
+			TokenAddress: chain.GetTokenAddress(),
+			ChainIndex:   int(chain.GetChainIndex()),
+		},
+	}
+	m.ActivateAt, m.ExpiresAt = pm.GetActivateAt(), pm.GetExpiresAt()
+	m.SchemaVersion = int(pm.GetSchemaVersion())
+	return nil
+}
+
+func decodeOrderV1(codec utils.Codec, body []byte, strict bool) (*model.OrderData, error) {
+	var msg orderMessageV1
+	if err := codec.Unmarshal(body, &msg, strict); err != nil {
+		return nil, err
+	}
+	if msg.OrderInfo.OrderID == "" {
+		return nil, errors.New("缺少订单ID")
+	}
+	return &model.OrderData{
+		OrderID:        msg.OrderInfo.OrderID,
+		Status:         msg.OrderInfo.Status,
+		EventTimestamp: msg.OrderInfo.EventTimestamp,
+		EventType:      msg.OrderInfo.EventType,
+
+		StrategyID:   int64(msg.StrategyInfo.StrategyId),
+		UserID:       msg.StrategyInfo.UserInfo.UserId,
+		BscPublicKey: msg.StrategyInfo.UserInfo.BscPublicKey,
+		SolPublicKey: msg.StrategyInfo.UserInfo.SolPublicKey,
+
+		TokenAddress: msg.ChainInfo.TokenAddress,
+		ChainIndex:   msg.ChainInfo.ChainIndex,
+
+		ActivateAt: msToTime(msg.ActivateAt),
+		ExpiresAt:  msToTime(msg.ExpiresAt),
+
+		Metadata: model.JSONB{
+			"order": msg.Order,
+		},
+	}, nil
+}
+
+// decodeOrderMessage 按消息版本选择解码器，得到当前格式的订单。
+// 格式错误和未知版本都是永久错误，消息带着原因进入死信队列。
+func decodeOrderMessage(d amqp.Delivery, strict bool) (*model.OrderData, error) {
+	codec, err := utils.CodecForContentType(d.ContentType)
+	if err != nil {
+		return nil, utils.Permanent(err)
+	}
+	version, err := messageSchemaVersion(codec, d)
+	if err != nil {
+		return nil, utils.Permanent(err)
+	}
+	decode, ok := ingestDecoders[version]
+	if !ok {
+		return nil, utils.Permanent(fmt.Errorf("%w: %d", ErrUnknownSchemaVersion, version))
+	}
+	order, err := decode(codec, d.Body, strict)
+	if err != nil {
+		return nil, utils.Permanent(fmt.Errorf("无法解析 v%d 消息: %w", version, err))
+	}
+	return order, nil
+}
+
+// messageSchemaVersion 读取消息头和消息体中的版本号，两处都有时必须一致
+func messageSchemaVersion(codec utils.Codec, d amqp.Delivery) (int, error) {
+	header, hasHeader, err := headerSchemaVersion(d.Headers)
+	if err != nil {
+		return 0, err
+	}
+
+	var probe schemaVersionProbe
+	if err := codec.Unmarshal(d.Body, &probe, false); err != nil {
+		return 0, fmt.Errorf("无法解析消息: %w", err)
+	}
+
+	switch {
+	case hasHeader && probe.SchemaVersion != nil && *probe.SchemaVersion != header:
+		return 0, fmt.Errorf("消息头 schema_version=%d 与消息体 schema_version=%d 不一致", header, *probe.SchemaVersion)
+	case hasHeader:
+		return header, nil
+	case probe.SchemaVersion != nil:
+		return *probe.SchemaVersion, nil
+	}
+	return 1, nil
+}
+
+// schemaVersionProbe 只读取消息体中的版本号
+type schemaVersionProbe struct {
+	SchemaVersion *int `json:"schema_version"`
+}
+
+// FromProto protobuf 消息只有 OrderMessage 带版本号
+func (p *schemaVersionProbe) FromProto(msg proto.Message) error {
+	if pm, ok := msg.(*pb.OrderMessage); ok && pm.SchemaVersion != nil {
+		version := int(pm.GetSchemaVersion())
+		p.SchemaVersion = &version
+	}
+	return nil
+}
+
+// headerSchemaVersion 消息头中的版本号，发布方可能写成有符号、无符号整数、浮点数或字符串。
+// 浮点数必须是整数值。
+func headerSchemaVersion(headers amqp.Table) (int, bool, error) {
+	v, ok := headers[SchemaVersionField]
+	if !ok {
+		return 0, false, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, true, nil
+	case int8:
+		return int(n), true, nil
+	case int16:
+		return int(n), true, nil
+	case int32:
+		return int(n), true, nil
+	case int64:
+		return int(n), true, nil
+	case uint8:
+		return int(n), true, nil
+	case uint16:
+		return int(n), true, nil
+	case uint32:
+		return int(n), true, nil
+	case uint64:
+		if n > math.MaxInt32 {
+			return 0, false, fmt.Errorf("消息头 schema_version 超出范围: %d", n)
+		}
+		return int(n), true, nil
+	case float32:
+		return floatSchemaVersion(float64(n))
+	case float64:
+		return floatSchemaVersion(n)
+	case string:
+		version, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, false, fmt.Errorf("消息头 schema_version 格式错误: %q", n)
+		}
+		return version, true, nil
+	}
+	return 0, false, fmt.Errorf("消息头 schema_version 类型错误: %T", v)
+}
+
+func floatSchemaVersion(f float64) (int, bool, error) {
+	if f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, false, fmt.Errorf("消息头 schema_version 不是整数: %v", f)
+	}
+	return int(f), true, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+)
+
+// errAny 只要求返回永久错误，不检查具体错误
+var errAny = errors.New("any")
+
+func testOrderMessage(version int) orderMessageV1 {
+	var msg orderMessageV1
+	msg.OrderInfo.OrderID = "o-1"
+	msg.OrderInfo.Status = "open"
+	msg.OrderInfo.EventType = "limit"
+	msg.StrategyInfo.StrategyId = 1001
+	msg.StrategyInfo.UserInfo.UserId = "u-1"
+	msg.ChainInfo.ChainIndex = 56
+	msg.ChainInfo.TokenAddress = "0xabc"
+	msg.ActivateAt = 1700000000000
+	msg.SchemaVersion = version
+	return msg
+}
+
+func encodeDelivery(t *testing.T, codec utils.Codec, v interface{}, headers amqp.Table) amqp.Delivery {
+	t.Helper()
+	body, err := codec.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return amqp.Delivery{ContentType: codec.ContentType(), Body: body, Headers: headers}
+}
+
+func mustCodec(t *testing.T, contentType string) utils.Codec {
+	t.Helper()
+	codec, err := utils.CodecForContentType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestDecodeOrderMessage(t *testing.T) {
+	for _, contentType := range []string{utils.ContentTypeJSON, utils.ContentTypeMsgpack} {
+		codec := mustCodec(t, contentType)
+		tests := []struct {
+			name    string
+			body    interface{}
+			headers amqp.Table
+			wantErr error
+		}{
+			{name: "无版本号", body: testOrderMessage(0)},
+			{name: "消息体版本号", body: testOrderMessage(1)},
+			{name: "消息头版本号", body: testOrderMessage(0), headers: amqp.Table{SchemaVersionField: int32(1)}},
+			{name: "版本号一致", body: testOrderMessage(1), headers: amqp.Table{SchemaVersionField: "1"}},
+			{name: "版本号不一致", body: testOrderMessage(1), headers: amqp.Table{SchemaVersionField: int64(2)}, wantErr: errAny},
+			{name: "未知版本", body: testOrderMessage(9), wantErr: ErrUnknownSchemaVersion},
+			{name: "缺少订单ID", body: models.Order{}, wantErr: errAny},
+		}
+		for _, tt := range tests {
+			t.Run(contentType+"/"+tt.name, func(t *testing.T) {
+				order, err := decodeOrderMessage(encodeDelivery(t, codec, tt.body, tt.headers), false)
+				if tt.wantErr != nil {
+					if !utils.IsPermanent(err) {
+						t.Fatalf("err = %v, want 永久错误", err)
+					}
+					if tt.wantErr != errAny && !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("decodeOrderMessage: %v", err)
+				}
+				if order.OrderID != "o-1" || order.Status != "open" || order.StrategyID != 1001 ||
+					order.UserID != "u-1" || order.ChainIndex != 56 || order.EventType != "limit" {
+					t.Fatalf("解码结果错误: %+v", order)
+				}
+				if order.ActivateAt == nil || order.ActivateAt.UnixMilli() != 1700000000000 || order.ExpiresAt != nil {
+					t.Fatalf("调度字段错误: activate_at=%v expires_at=%v", order.ActivateAt, order.ExpiresAt)
+				}
+				if _, ok := order.Metadata["order"]; !ok {
+					t.Fatal("Metadata 中缺少原始消息")
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeOrderMessageStrict(t *testing.T) {
+	for _, contentType := range []string{utils.ContentTypeJSON, utils.ContentTypeMsgpack} {
+		codec := mustCodec(t, contentType)
+		body := struct {
+			orderMessageV1
+			Unknown int `json:"unknown_at"`
+		}{testOrderMessage(1), 1}
+		d := encodeDelivery(t, codec, body, nil)
+		if _, err := decodeOrderMessage(d, false); err != nil {
+			t.Errorf("%s 非严格模式应忽略未知字段: %v", contentType, err)
+		}
+		if _, err := decodeOrderMessage(d, true); !utils.IsPermanent(err) {
+			t.Errorf("%s 严格模式应拒绝未知字段，err = %v", contentType, err)
+		}
+	}
+}
+
+func TestDecodeOrderMessageContentType(t *testing.T) {
+	d := amqp.Delivery{ContentType: "application/x-protobuf", Body: []byte{0x0a}}
+	_, err := decodeOrderMessage(d, false)
+	if !utils.IsPermanent(err) || !errors.Is(err, utils.ErrUnsupportedContentType) {
+		t.Fatalf("err = %v, want 不支持的 content_type 永久错误", err)
+	}
+}
+
+func TestHeaderSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   amqp.Table
+		want      int
+		wantFound bool
+		wantErr   bool
+	}{
+		{"未设置", nil, 0, false, false},
+		{"int", amqp.Table{SchemaVersionField: 2}, 2, true, false},
+		{"int8", amqp.Table{SchemaVersionField: int8(2)}, 2, true, false},
+		{"int16", amqp.Table{SchemaVersionField: int16(2)}, 2, true, false},
+		{"int32", amqp.Table{SchemaVersionField: int32(2)}, 2, true, false},
+		{"int64", amqp.Table{SchemaVersionField: int64(2)}, 2, true, false},
+		{"uint8", amqp.Table{SchemaVersionField: uint8(2)}, 2, true, false},
+		{"uint16", amqp.Table{SchemaVersionField: uint16(2)}, 2, true, false},
+		{"uint32", amqp.Table{SchemaVersionField: uint32(2)}, 2, true, false},
+		{"uint64", amqp.Table{SchemaVersionField: uint64(2)}, 2, true, false},
+		{"uint64 超出范围", amqp.Table{SchemaVersionField: uint64(math.MaxInt32) + 1}, 0, false, true},
+		{"float32", amqp.Table{SchemaVersionField: float32(2)}, 2, true, false},
+		{"float64", amqp.Table{SchemaVersionField: float64(2)}, 2, true, false},
+		{"float64 非整数", amqp.Table{SchemaVersionField: 1.5}, 0, false, true},
+		{"float64 超出范围", amqp.Table{SchemaVersionField: 1e12}, 0, false, true},
+		{"字符串", amqp.Table{SchemaVersionField: "2"}, 2, true, false},
+		{"字符串格式错误", amqp.Table{SchemaVersionField: "v2"}, 0, false, true},
+		{"不支持的类型", amqp.Table{SchemaVersionField: true}, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := headerSchemaVersion(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || found != tt.wantFound {
+				t.Fatalf("got (%d, %v), want (%d, %v)", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
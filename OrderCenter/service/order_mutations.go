@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+)
+
+// ErrOrderNotFound 订单不存在，接口返回 404
+var ErrOrderNotFound = errors.New("订单不存在")
+
+// ErrOrderClosed 订单已过期或已撤单，不能再修改或撤单，接口返回 409
+var ErrOrderClosed = errors.New("订单已处于终态")
+
+// ErrInvalidUpdate 更新内容无效，接口返回 400
+var ErrInvalidUpdate = errors.New("更新内容无效")
+
+// validateUpdate 订单中心维护的状态只能由调度、过期和撤单流程写入
+func validateUpdate(updated *model.OrderData) error {
+	switch updated.Status {
+	case model.OrderStatusScheduled, model.OrderStatusExpired, model.OrderStatusWithdrawn:
+		return fmt.Errorf("%w: 不能把状态改为 %s", ErrInvalidUpdate, updated.Status)
+	}
+	return nil
+}
+
+// closedOrMissing 锁定不到未进入终态的订单时，区分订单不存在和已处于终态
+func closedOrMissing(ctx context.Context, repo *repository.OrderRepository, orderID string) error {
+	statuses, err := repo.FindStatuses(ctx, []string{orderID})
+	if err != nil {
+		return fmt.Errorf("检查订单失败: %w", err)
+	}
+	status, ok := statuses[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	return fmt.Errorf("%w %s", ErrOrderClosed, status)
+}
+
+// updateLocked 更新已锁定的订单并写入历史，返回更新后的订单。需在事务中调用。
+// 调度中的订单保持 scheduled 和原生效时间，修改的状态暂存到 pending_status，生效时再使用。
+func updateLocked(ctx context.Context, tx *repository.OrderRepository, before *model.OrderData, updated *model.OrderData) (*model.OrderData, error) {
+	// 主键不参与更新
+	u := *updated
+	u.OrderID = ""
+	updated = &u
+	if before.Status == model.OrderStatusScheduled {
+		updated = keepScheduled(before, updated)
+	}
+	if err := tx.Update(ctx, before.OrderID, updated); err != nil {
+		return nil, err
+	}
+	after, err := tx.GetByID(ctx, before.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.CreateHistory(ctx, &model.OrderHistory{
+		OrderID:    before.OrderID,
+		Action:     model.HistoryActionUpdate,
+		FromStatus: before.Status,
+		ToStatus:   after.Status,
+		Snapshot:   model.JSONB{"order": before},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// withdrawLocked 把已锁定的订单置为 withdrawn 并写入历史，记录保留。
+// 需在事务中调用，orders 为变更前的快照。
+func withdrawLocked(ctx context.Context, tx *repository.OrderRepository, orders []model.OrderData) error {
+	ids := make([]string, len(orders))
+	histories := make([]*model.OrderHistory, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderID
+		histories[i] = &model.OrderHistory{
+			OrderID:    orders[i].OrderID,
+			Action:     model.HistoryActionWithdraw,
+			FromStatus: orders[i].Status,
+			ToStatus:   model.OrderStatusWithdrawn,
+			Snapshot:   model.JSONB{"order": orders[i]},
+		}
+	}
+	if err := tx.CreateHistoryBatch(ctx, histories); err != nil {
+		return fmt.Errorf("写入撤单历史失败: %w", err)
+	}
+	if _, err := tx.UpdateStatusByIDs(ctx, ids, model.OrderStatusWithdrawn); err != nil {
+		return fmt.Errorf("撤单失败: %w", err)
+	}
+	return nil
+}
+
+// withdrawnChanges 撤单事件对应的变更
+func withdrawnChanges(befores []model.OrderData) []LifecycleChange {
+	changes := make([]LifecycleChange, len(befores))
+	for i := range befores {
+		after := befores[i]
+		after.Status = model.OrderStatusWithdrawn
+		changes[i] = LifecycleChange{Before: &befores[i], After: &after}
+	}
+	return changes
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"trade-solution/ordercenter/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	lockOpenSQL   = "SELECT \\* FROM `order_data` WHERE order_id IN .* AND status NOT IN .* FOR UPDATE"
+	findStatusSQL = "SELECT order_id, status FROM `order_data`"
+	getOrderSQL   = "SELECT \\* FROM `order_data` WHERE order_id = \\?"
+)
+
+var orderColumns = []string{"order_id", "status", "token_address"}
+
+func TestValidateUpdate(t *testing.T) {
+	for _, status := range []string{model.OrderStatusScheduled, model.OrderStatusExpired, model.OrderStatusWithdrawn} {
+		if err := validateUpdate(&model.OrderData{Status: status}); !errors.Is(err, ErrInvalidUpdate) {
+			t.Errorf("status %s: err = %v, want ErrInvalidUpdate", status, err)
+		}
+	}
+	for _, status := range []string{"", "open", "paused"} {
+		if err := validateUpdate(&model.OrderData{Status: status}); err != nil {
+			t.Errorf("status %q: err = %v", status, err)
+		}
+	}
+}
+
+// 已撤单或已过期的订单不能再更新或撤单，与不存在的订单区分开
+func TestMutateClosedOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// 订单当前状态，为空表示不存在
+		status string
+		want   error
+	}{
+		{"已撤单", model.OrderStatusWithdrawn, ErrOrderClosed},
+		{"已过期", model.OrderStatusExpired, ErrOrderClosed},
+		{"不存在", "", ErrOrderNotFound},
+	}
+	statusRows := func(status string) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"order_id", "status"})
+		if status != "" {
+			rows.AddRow("o-1", status)
+		}
+		return rows
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/更新", func(t *testing.T) {
+			srv, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns))
+			mock.ExpectQuery(findStatusSQL).WillReturnRows(statusRows(tt.status))
+			mock.ExpectRollback()
+
+			err := srv.UpdateOrder(context.Background(), "o-1", &model.OrderData{TokenAddress: "0xdef"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/撤单", func(t *testing.T) {
+			srv, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns))
+			mock.ExpectCommit()
+			mock.ExpectQuery(findStatusSQL).WillReturnRows(statusRows(tt.status))
+
+			err := srv.DeleteOrder(context.Background(), "o-1")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// 撤单只把状态改为 withdrawn，不删除记录
+func TestDeleteOrderMarksWithdrawn(t *testing.T) {
+	srv, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns).AddRow("o-1", "open", "0xabc"))
+	mock.ExpectExec("INSERT INTO `order_history`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_data` SET `status`=?,`updated_at`=? WHERE order_id IN (?)")).
+		WithArgs(model.OrderStatusWithdrawn, sqlmock.AnyArg(), "o-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := srv.DeleteOrder(context.Background(), "o-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithdrawnChanges(t *testing.T) {
+	befores := []model.OrderData{{OrderID: "o-1", Status: "open"}, {OrderID: "o-2", Status: model.OrderStatusScheduled}}
+	changes := withdrawnChanges(befores)
+	for i, c := range changes {
+		if c.Before.Status != befores[i].Status || c.After == nil || c.After.Status != model.OrderStatusWithdrawn {
+			t.Fatalf("第 %d 条: before = %+v, after = %+v", i, c.Before, c.After)
+		}
+	}
+}
+
+// expectOrderUpdate 一条订单更新：UPDATE、读回更新后的订单、写入历史
+func expectOrderUpdate(mock sqlmock.Sqlmock, orderID, token string) {
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_data` SET `token_address`=?,`updated_at`=? WHERE order_id = ?")).
+		WithArgs(token, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(getOrderSQL).WillReturnRows(sqlmock.NewRows(orderColumns).AddRow(orderID, "open", token))
+	mock.ExpectExec("INSERT INTO `order_history`").WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestUpdateOrders(t *testing.T) {
+	srv, mock := newMockService(t)
+	orders := []model.OrderData{
+		{OrderID: "o-1", TokenAddress: "0x1"},
+		{OrderID: "o-2", TokenAddress: "0x2"},
+	}
+
+	mock.ExpectQuery(findStatusSQL).WillReturnRows(sqlmock.NewRows([]string{"order_id", "status"}).
+		AddRow("o-1", "open").AddRow("o-2", "open"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns).
+		AddRow("o-1", "open", "0xa").AddRow("o-2", "open", "0xb"))
+	expectOrderUpdate(mock, "o-1", "0x1")
+	expectOrderUpdate(mock, "o-2", "0x2")
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	res, err := srv.UpdateOrders(context.Background(), orders, BatchModeAtomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Succeeded != 2 || res.Failed != 0 || res.RolledBack {
+		t.Fatalf("result = %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateOrdersRejected(t *testing.T) {
+	orders := []model.OrderData{
+		{OrderID: "o-1", TokenAddress: "0x1"},
+		{OrderID: "o-2", TokenAddress: "0x2"},
+		{OrderID: "o-3", Status: model.OrderStatusWithdrawn},
+		{OrderID: "o-4", TokenAddress: "0x4"},
+	}
+	statuses := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"order_id", "status"}).
+			AddRow("o-1", "open").AddRow("o-2", model.OrderStatusExpired).AddRow("o-3", "open")
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		srv, mock := newMockService(t)
+		mock.ExpectQuery(findStatusSQL).WillReturnRows(statuses())
+
+		res, err := srv.UpdateOrders(context.Background(), orders, BatchModeAtomic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.RolledBack || res.Succeeded != 0 || res.Failed != 4 {
+			t.Fatalf("result = %+v", res)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("best_effort", func(t *testing.T) {
+		srv, mock := newMockService(t)
+		mock.ExpectQuery(findStatusSQL).WillReturnRows(statuses())
+		// 校验之后 o-1 被并发撤单，整批回滚后逐条更新
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOpenSQL).WillReturnRows(sqlmock.NewRows(orderColumns))
+		mock.ExpectQuery(findStatusSQL).WillReturnRows(sqlmock.NewRows([]string{"order_id", "status"}).
+			AddRow("o-1", model.OrderStatusWithdrawn))
+		mock.ExpectRollback()
+
+		res, err := srv.UpdateOrders(context.Background(), orders, BatchModeBestEffort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, item := range res.Results {
+			if item.Success || item.Error == "" {
+				t.Errorf("第 %d 条: %+v", i, item)
+			}
+		}
+		if !strings.Contains(res.Results[0].Error, ErrOrderClosed.Error()) {
+			t.Errorf("o-1: %s, want %v", res.Results[0].Error, ErrOrderClosed)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
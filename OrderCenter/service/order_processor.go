@@ -2,121 +2,55 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
-	"trade-solution/common/go/lib/models"
-	"trade-solution/ordercenter/model"
-	"trade-solution/ordercenter/repository"
+	"time"
+	"trade-solution/ordercenter/logging"
 	"trade-solution/ordercenter/utils"
 
-	"gorm.io/gorm"
+	"github.com/streadway/amqp"
 )
 
-// 并发 worker
-func StartMultiStrategyConsumer(queueName string, rmq *utils.RabbitMQ, workerCount int, prefetch int, db *gorm.DB) error {
-	// 设置 QoS：每个 worker 最多同时处理 prefetch 条未确认的消息
-	if err := rmq.Channel.Qos(prefetch, 0, false); err != nil {
-		return fmt.Errorf("设置 Qos 失败: %w", err)
+// PushRoutingKey 推送交换机的 routingKey：order.<chain>.<event_type>
+// event_type 中的 "." 会被替换，避免破坏 topic 的分段
+func PushRoutingKey(chainIndex int, eventType string) string {
+	if eventType == "" {
+		eventType = "unknown"
 	}
+	eventType = strings.ReplaceAll(eventType, ".", "_")
+	return fmt.Sprintf("order.%d.%s", chainIndex, eventType)
+}
 
-	deliveries, err := rmq.Consume(queueName)
-	if err != nil {
-		return fmt.Errorf("从队列 %s 消费失败: %w", queueName, err)
-	}
-	log.Printf("📥 开始消费队列: %s，workers=%d prefetch=%d", queueName, workerCount, prefetch)
-
-	ctx := context.Background()
-
-	// 启动多个 worker 并发处理消息
-	for i := 0; i < workerCount; i++ {
-		workerID := i
-		repo := repository.NewOrderRepository(db.Session(&gorm.Session{NewDB: true}))
-		srv := NewOrderService(repo)
+// orderSchedule 上游消息中可选的调度字段，Unix 毫秒
+type orderSchedule struct {
+	ActivateAt int64 `json:"activate_at"`
+	ExpiresAt  int64 `json:"expires_at"`
+}
 
-		go func(workerID int, srv *OrderService) {
-			for d := range deliveries {
-				// 处理单条消息
-				if err := handleMessage(ctx, d.Body, rmq, srv); err != nil {
-					log.Printf("❌ worker-%d 处理消息失败: %v", workerID, err)
-					//// —— 新增 debug 打印 ——
-					//log.Printf("🛠 原始 JSON：%s", d.Body)
-					d.Nack(false, false) // 拒绝并重新入队
-					continue
-				}
-				d.Ack(false) // 成功确认
-			}
-		}(workerID, srv)
+// msToTime 把 Unix 毫秒转换为时间，0 表示未设置
+func msToTime(ms int64) *time.Time {
+	if ms <= 0 {
+		return nil
 	}
-
-	return nil
+	t := time.UnixMilli(ms)
+	return &t
 }
 
 // 单条消息处理逻辑
-func handleMessage(ctx context.Context, body []byte, rmq *utils.RabbitMQ, srv *OrderService) error {
-	var msg models.Order
-	if err := json.Unmarshal(body, &msg); err != nil {
-		return fmt.Errorf("无法解析消息: %w", err)
-	}
-
-	order := &model.OrderData{
-		OrderID:        msg.OrderInfo.OrderID,
-		Status:         msg.OrderInfo.Status,
-		EventTimestamp: msg.OrderInfo.EventTimestamp,
-		EventType:      msg.OrderInfo.EventType,
-
-		StrategyID:   int64(msg.StrategyInfo.StrategyId),
-		UserID:       msg.StrategyInfo.UserInfo.UserId,
-		BscPublicKey: msg.StrategyInfo.UserInfo.BscPublicKey,
-		SolPublicKey: msg.StrategyInfo.UserInfo.SolPublicKey,
-
-		TokenAddress: msg.ChainInfo.TokenAddress,
-		ChainIndex:   msg.ChainInfo.ChainIndex,
-
-		Metadata: model.JSONB{
-			"order": msg,
-		},
-
-		//Metadata: model.JSONB{
-		//	"order": msg,
-		//},
-		//CreatedAt: time.Now(),
-		//UpdatedAt: time.Now(),
-	}
-
-	// 写入数据库（捕获重复主键错误）
-	if err := srv.CreateOrder(order); err != nil {
-		if strings.Contains(err.Error(), "订单已存在") {
-			log.Printf("⚠️ 订单已存在，跳过：%s", order.OrderID)
-			return nil
-		}
-		return fmt.Errorf("写入数据库失败: %w", err)
-	}
-
-	log.Printf("✅ 成功写入订单：%s", order.OrderID)
-
-	/*查数据库 在发布？*/
-	/*		var fullOrder model.OrderData
-			if err := repo.DB.WithContext(ctx).First(&fullOrder, "order_id = ?", order.OrderID).Error; err != nil {
-				return fmt.Errorf("查询数据库失败: %w", err)
-			}
-		bodyData, err := json.Marshal(fullOrder.Metadata["order"])*/
-
-	// 推入下游队列 交换机
-	pushExchange := "order_push_exchange"
-	routingKey := "new_order"
-
-	bodyData, err := json.Marshal(msg)
+func handleMessage(ctx context.Context, d amqp.Delivery, srv *OrderService) error {
+	order, err := decodeOrderMessage(d, srv.strictIngest)
 	if err != nil {
-		return fmt.Errorf("序列化推送内容失败: %w", err)
+		return err
 	}
+	ctx = logging.WithOrderID(ctx, order.OrderID)
 
-	if err := rmq.Publish(pushExchange, routingKey, bodyData, 0); err != nil {
-		return fmt.Errorf("发布到交换机失败: %w", err)
+	// 写入数据库并推送，与 REST 创建共用同一流程
+	if err := srv.CreateAndPublishOrder(ctx, order); err != nil {
+		if errors.Is(err, ErrOrderExists) {
+			return fmt.Errorf("%w: 订单已存在 %s", utils.ErrDuplicate, order.OrderID)
+		}
+		return err
 	}
-
-	log.Printf("📤 已推送至交换机 %s (routingKey=%s): orderId=%s userId=%s", pushExchange, routingKey, order.OrderID, msg.StrategyInfo.UserInfo.UserId)
-
 	return nil
 }
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/utils"
+)
+
+// 批量消息处理逻辑：一次写入整批订单和推送事件，每条消息按自己的结果确认
+func handleMessageBatch(ctx context.Context, items []utils.BatchItem, srv *OrderService) []error {
+	errs := make([]error, len(items))
+	orders := make([]*model.OrderData, 0, len(items))
+	ctxs := make([]context.Context, 0, len(items))
+	positions := make([]int, 0, len(items))
+	for i, item := range items {
+		order, err := decodeOrderMessage(item.Delivery, srv.strictIngest)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		orders = append(orders, order)
+		ctxs = append(ctxs, item.Ctx)
+		positions = append(positions, i)
+	}
+
+	for j, err := range srv.insertNewOrders(ctx, orders, ctxs) {
+		i, order := positions[j], orders[j]
+		switch {
+		case errors.Is(err, ErrOrderExists):
+			errs[i] = fmt.Errorf("%w: 订单已存在 %s", utils.ErrDuplicate, order.OrderID)
+		case err != nil:
+			errs[i] = err
+		default:
+			srv.logCreated(logging.WithOrderID(items[i].Ctx, order.OrderID), order)
+		}
+	}
+	return errs
+}
+
+// errBatchConflict 多行插入时有订单已被其他事务写入，整批回滚后逐条写入
+var errBatchConflict = errors.New("批量写入时订单已被并发写入")
+
+// insertNewOrders 批量写入新订单，返回与 orders 一一对应的错误：已存在的订单返回 ErrOrderExists，
+// 已处理过的消息返回 ErrMessageProcessed。ctxs 为每个订单所属消息的 ctx，
+// 带有消息 ID、请求 ID 和 trace，推送事件按各自的 ctx 写入。
+// 先一次查出已存在的订单，其余与消息登记、推送事件一起在一个事务中用跳过已存在行的多行插入写入；
+// 插入行数少于预期说明期间有并发写入，与其他失败一样回滚后逐条写入，只有出错的订单返回错误。
+func (s *OrderService) insertNewOrders(ctx context.Context, orders []*model.OrderData, ctxs []context.Context) []error {
+	errs := make([]error, len(orders))
+	if len(orders) == 0 {
+		return errs
+	}
+
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.OrderID
+	}
+	existing, err := s.repo.FindExistingIDs(ctx, ids)
+	if err != nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("检查订单失败: %w", err)
+		}
+		return errs
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(orders))
+	pending := make([]int, 0, len(orders))
+	for i, order := range orders {
+		// 同一批中重复的消息只写入第一条
+		if existing[order.OrderID] || seen[order.OrderID] {
+			errs[i] = ErrOrderExists
+			continue
+		}
+		seen[order.OrderID] = true
+		s.prepareNewOrder(order, now)
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return errs
+	}
+
+	keys := make([]messageKey, len(ctxs))
+	for i := range ctxs {
+		keys[i] = messageKeyFrom(ctxs[i])
+	}
+	var processed map[int]bool
+	err = s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		var err error
+		if processed, err = s.claimMessages(ctx, tx, keys, pending); err != nil {
+			return err
+		}
+		rows := make([]*model.OrderData, 0, len(pending))
+		rowCtxs := make([]context.Context, 0, len(pending))
+		for _, i := range pending {
+			if !processed[i] {
+				rows = append(rows, orders[i])
+				rowCtxs = append(rowCtxs, ctxs[i])
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		inserted, err := tx.CreateBatchSkipExisting(ctx, rows)
+		if err != nil {
+			return err
+		}
+		if inserted != int64(len(rows)) {
+			return errBatchConflict
+		}
+		return s.events.WriteCreatedEach(ctx, tx, rows, rowCtxs, now)
+	})
+	if err == nil {
+		for i := range processed {
+			errs[i] = ErrMessageProcessed
+		}
+		return errs
+	}
+	s.logger.WarnContext(ctx, "批量写入失败，改为逐条写入", "count", len(pending), logging.Err(err))
+	for _, i := range pending {
+		if err := s.insertOrder(ctxs[i], orders[i]); err != nil {
+			if !errors.Is(err, ErrMessageProcessed) {
+				err = fmt.Errorf("写入订单失败: %w", err)
+			}
+			errs[i] = err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/streadway/amqp"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockProvider 返回使用 sqlmock 连接的 DBProvider
+func newMockProvider(t *testing.T) (*repository.DBProvider, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository.NewDBProvider(db), mock
+}
+
+// newMockService 返回使用 sqlmock 连接的 OrderService，事件写入发件箱但不连接 RabbitMQ
+func newMockService(t *testing.T, opts ...OrderServiceOption) (*OrderService, sqlmock.Sqlmock) {
+	t.Helper()
+	provider, mock := newMockProvider(t)
+	repo := repository.NewOrderRepository(provider)
+	opts = append([]OrderServiceOption{WithEventPublisher(NewEventPublisher(&utils.RabbitMQ{}, "test", false))}, opts...)
+	srv := NewOrderService(repo, opts...)
+	return srv, mock
+}
+
+func batchItem(d amqp.Delivery) utils.BatchItem {
+	return utils.BatchItem{Ctx: context.Background(), Delivery: d}
+}
+
+func orderDelivery(t *testing.T, orderID string, version int) amqp.Delivery {
+	t.Helper()
+	msg := testOrderMessage(version)
+	msg.OrderInfo.OrderID = orderID
+	// 不走调度，入库后立即写入推送事件
+	msg.ActivateAt = 0
+	return encodeDelivery(t, mustCodec(t, utils.ContentTypeJSON), msg, nil)
+}
+
+// errPermanent 只要求返回永久错误，不检查具体错误
+var errPermanent = errors.New("permanent")
+
+// checkBatchErrs 按期望逐条检查结果：nil 表示成功，errPermanent 表示永久错误，其余按 errors.Is 比较
+func checkBatchErrs(t *testing.T, got, want []error) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("返回 %d 条结果, want %d", len(got), len(want))
+	}
+	for i := range want {
+		switch {
+		case want[i] == nil:
+			if got[i] != nil {
+				t.Errorf("第 %d 条: err = %v, want nil", i, got[i])
+			}
+		case want[i] == errPermanent:
+			if !utils.IsPermanent(got[i]) {
+				t.Errorf("第 %d 条: err = %v, want 永久错误", i, got[i])
+			}
+		case !errors.Is(got[i], want[i]):
+			t.Errorf("第 %d 条: err = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHandleMessageBatch(t *testing.T) {
+	srv, mock := newMockService(t)
+	items := []utils.BatchItem{
+		batchItem(amqp.Delivery{ContentType: utils.ContentTypeJSON, Body: []byte("{")}),
+		batchItem(orderDelivery(t, "o-1", 1)),
+		batchItem(orderDelivery(t, "o-2", 1)),
+		batchItem(orderDelivery(t, "o-3", 9)),
+		// 同一批中重复的消息只写入第一条
+		batchItem(orderDelivery(t, "o-2", 1)),
+	}
+
+	mock.ExpectQuery("SELECT .*`order_data`").
+		WillReturnRows(sqlmock.NewRows([]string{"order_id"}).AddRow("o-1"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	errs := handleMessageBatch(context.Background(), items, srv)
+	checkBatchErrs(t, errs, []error{errPermanent, utils.ErrDuplicate, nil, errPermanent, utils.ErrDuplicate})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleMessageBatchFallback(t *testing.T) {
+	srv, mock := newMockService(t)
+	items := []utils.BatchItem{
+		batchItem(orderDelivery(t, "o-1", 1)),
+		batchItem(orderDelivery(t, "o-2", 1)),
+		batchItem(orderDelivery(t, "o-3", 1)),
+	}
+	errInsert := errors.New("insert failed")
+
+	mock.ExpectQuery("SELECT .*`order_data`").WillReturnRows(sqlmock.NewRows([]string{"order_id"}))
+	// 多行插入失败，整批回滚
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnError(errInsert)
+	mock.ExpectRollback()
+	// 逐条写入：o-1 成功，o-2 被并发写入，o-3 失败
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnError(&gomysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnError(errInsert)
+	mock.ExpectRollback()
+
+	errs := handleMessageBatch(context.Background(), items, srv)
+	checkBatchErrs(t, errs, []error{nil, utils.ErrDuplicate, errInsert})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleMessageBatchConflict(t *testing.T) {
+	srv, mock := newMockService(t)
+	items := []utils.BatchItem{
+		batchItem(orderDelivery(t, "o-1", 1)),
+		batchItem(orderDelivery(t, "o-2", 1)),
+	}
+
+	mock.ExpectQuery("SELECT .*`order_data`").WillReturnRows(sqlmock.NewRows([]string{"order_id"}))
+	// 查询之后 o-2 被并发写入，插入行数少于预期，回滚后逐条写入
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnError(&gomysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	errs := handleMessageBatch(context.Background(), items, srv)
+	checkBatchErrs(t, errs, []error{nil, utils.ErrDuplicate})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// outboxRowArgs 多行写入发件箱的参数，只检查每行的 correlation_id
+func outboxRowArgs(correlationIDs ...string) []driver.Value {
+	// event_id 到 created_at 共 12 列，correlation_id 是第 8 列
+	const columns, correlationColumn = 12, 7
+	args := make([]driver.Value, 0, columns*len(correlationIDs))
+	for _, id := range correlationIDs {
+		for c := 0; c < columns; c++ {
+			if c == correlationColumn {
+				args = append(args, id)
+			} else {
+				args = append(args, sqlmock.AnyArg())
+			}
+		}
+	}
+	return args
+}
+
+// 整批写入时每条推送事件带上自己消息的请求 ID，而不是批次的
+func TestHandleMessageBatchCorrelationID(t *testing.T) {
+	srv, mock := newMockService(t)
+	items := []utils.BatchItem{
+		{Ctx: logging.WithRequestID(context.Background(), "req-1"), Delivery: orderDelivery(t, "o-1", 1)},
+		{Ctx: logging.WithRequestID(context.Background(), "req-2"), Delivery: orderDelivery(t, "o-2", 1)},
+	}
+
+	mock.ExpectQuery("SELECT .*`order_data`").WillReturnRows(sqlmock.NewRows([]string{"order_id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `order_data`").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WithArgs(outboxRowArgs("req-1", "req-2")...).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	errs := handleMessageBatch(logging.WithRequestID(context.Background(), "batch"), items, srv)
+	checkBatchErrs(t, errs, []error{nil, nil})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
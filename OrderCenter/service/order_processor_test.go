@@ -0,0 +1,41 @@
+package service
+
+import "testing"
+
+func TestPushRoutingKey(t *testing.T) {
+	tests := []struct {
+		chainIndex int
+		eventType  string
+		want       string
+	}{
+		{56, "buy", "order.56.buy"},
+		{501, "sell", "order.501.sell"},
+		{56, "", "order.56.unknown"},
+		// "." 会破坏 topic 的分段
+		{1, "limit.buy", "order.1.limit_buy"},
+		{0, "a.b.c", "order.0.a_b_c"},
+	}
+	for _, tt := range tests {
+		if got := PushRoutingKey(tt.chainIndex, tt.eventType); got != tt.want {
+			t.Errorf("PushRoutingKey(%d, %q) = %q, want %q", tt.chainIndex, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestLifecycleRoutingKey(t *testing.T) {
+	tests := []struct {
+		chainIndex int
+		eventType  string
+		action     string
+		want       string
+	}{
+		{56, "buy", lifecycleActionExpired, "order.56.buy.expired"},
+		{56, "", lifecycleActionWithdrawn, "order.56.unknown.withdrawn"},
+		{1, "limit.buy", lifecycleActionUpdated, "order.1.limit_buy.updated"},
+	}
+	for _, tt := range tests {
+		if got := LifecycleRoutingKey(tt.chainIndex, tt.eventType, tt.action); got != tt.want {
+			t.Errorf("LifecycleRoutingKey(%d, %q, %q) = %q, want %q", tt.chainIndex, tt.eventType, tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/config"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/pb"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	protoOrderContentType       = "application/x-protobuf; proto=ordercenter.v1.OrderMessage"
+	protoWithdrawAllContentType = "application/x-protobuf; proto=ordercenter.v1.WithdrawAllMessage"
+)
+
+func testProtoOrderMessage(version *int32) *pb.OrderMessage {
+	return &pb.OrderMessage{
+		OrderInfo: &pb.OrderInfo{OrderId: "o-1", Status: "open", EventType: "limit"},
+		StrategyInfo: &pb.StrategyInfo{
+			StrategyId: 1001,
+			UserInfo:   &pb.UserInfo{UserId: "u-1"},
+		},
+		ChainInfo:     &pb.ChainInfo{TokenAddress: "0xabc", ChainIndex: 56},
+		ActivateAt:    1700000000000,
+		SchemaVersion: version,
+	}
+}
+
+func TestDecodeOrderMessageProtobuf(t *testing.T) {
+	codec := mustCodec(t, protoOrderContentType)
+	tests := []struct {
+		name    string
+		body    *pb.OrderMessage
+		headers amqp.Table
+		wantErr error
+	}{
+		{name: "无版本号", body: testProtoOrderMessage(nil)},
+		{name: "消息体版本号", body: testProtoOrderMessage(proto.Int32(1))},
+		{name: "消息头版本号", body: testProtoOrderMessage(nil), headers: amqp.Table{SchemaVersionField: int32(1)}},
+		{name: "版本号不一致", body: testProtoOrderMessage(proto.Int32(1)), headers: amqp.Table{SchemaVersionField: int64(2)}, wantErr: errAny},
+		{name: "未知版本", body: testProtoOrderMessage(proto.Int32(9)), wantErr: ErrUnknownSchemaVersion},
+		{name: "缺少订单ID", body: &pb.OrderMessage{}, wantErr: errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := decodeOrderMessage(encodeDelivery(t, codec, tt.body, tt.headers), true)
+			if tt.wantErr != nil {
+				if !utils.IsPermanent(err) {
+					t.Fatalf("err = %v, want 永久错误", err)
+				}
+				if tt.wantErr != errAny && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeOrderMessage: %v", err)
+			}
+			if order.OrderID != "o-1" || order.Status != "open" || order.StrategyID != 1001 ||
+				order.UserID != "u-1" || order.ChainIndex != 56 || order.EventType != "limit" || order.TokenAddress != "0xabc" {
+				t.Fatalf("解码结果错误: %+v", order)
+			}
+			if order.ActivateAt == nil || order.ActivateAt.UnixMilli() != 1700000000000 || order.ExpiresAt != nil {
+				t.Fatalf("调度字段错误: activate_at=%v expires_at=%v", order.ActivateAt, order.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestWithdrawMessageProtobuf(t *testing.T) {
+	allCodec := mustCodec(t, protoWithdrawAllContentType)
+	d := encodeDelivery(t, allCodec, &pb.WithdrawAllMessage{
+		Scope: &pb.WithdrawScope{StrategyId: proto.Int64(1001), ChainIndex: proto.Int64(56)},
+	}, nil)
+
+	var probe withdrawTypeProbe
+	if err := allCodec.Unmarshal(d.Body, &probe, false); err != nil || probe.Type != withdrawTypeAll {
+		t.Fatalf("type = %q, err = %v", probe.Type, err)
+	}
+	var msg withdrawAllMessage
+	if err := allCodec.Unmarshal(d.Body, &msg, true); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Scope.StrategyID == nil || *msg.Scope.StrategyID != 1001 ||
+		msg.Scope.ChainIndex == nil || *msg.Scope.ChainIndex != 56 || msg.Scope.UserID != "" {
+		t.Fatalf("scope = %+v", msg.Scope)
+	}
+
+	// 单个订单的撤单消息没有 type
+	orderCodec := mustCodec(t, protoOrderContentType)
+	d = encodeDelivery(t, orderCodec, testProtoOrderMessage(nil), nil)
+	probe = withdrawTypeProbe{}
+	if err := orderCodec.Unmarshal(d.Body, &probe, false); err != nil || probe.Type != "" {
+		t.Fatalf("type = %q, err = %v", probe.Type, err)
+	}
+}
+
+func protobufPublisher(t *testing.T, legacy bool) *EventPublisher {
+	t.Helper()
+	topology := config.DefaultTopology()
+	if err := topology.SetEncodings(pushExchange + "=" + config.EncodingProtobuf); err != nil {
+		t.Fatal(err)
+	}
+	rmq, err := utils.NewRabbitMQ("", topology, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEventPublisher(rmq, "test", legacy)
+}
+
+// 发件箱中的事件按 order_event_exchange 配置的编码序列化
+func TestOutboxEventProtobuf(t *testing.T) {
+	p := protobufPublisher(t, false)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	order := &model.OrderData{OrderID: "o-1", Status: "open", ChainIndex: 56, EventType: "buy", CreatedAt: now}
+	event, err := p.outboxEvent(context.Background(), "e-1", EventOrderCreated, order.OrderID,
+		PushRoutingKey(order.ChainIndex, order.EventType), p.createdBody("e-1", order, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ContentType != "application/x-protobuf; proto=ordercenter.v1.OrderEvent" {
+		t.Fatalf("content_type = %q", event.ContentType)
+	}
+	var got pb.OrderEvent
+	if err := proto.Unmarshal(event.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.GetEventId() != "e-1" || got.GetEventType() != EventOrderCreated || got.GetSchemaVersion() != EventSchemaVersion ||
+		got.GetPayload().GetOrder().GetChainIndex() != 56 || got.GetPayload().GetBefore() != nil ||
+		!got.GetOccurredAt().AsTime().Equal(now.UTC()) {
+		t.Fatalf("事件内容错误: %v", &got)
+	}
+}
+
+func TestEventPublisherValidate(t *testing.T) {
+	if err := protobufPublisher(t, true).Validate(); err == nil {
+		t.Fatal("旧格式事件不能按 protobuf 推送")
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+)
+
+// OrderScheduler 轮询到期的调度订单，激活后写入推送事件。
+// 调度状态保存在 order_data 中，服务重启不会丢失；
+// 多实例同时运行时通过状态 CAS 保证每个订单只激活一次。
+type OrderScheduler struct {
+	repo      *repository.OrderRepository
+	events    *EventPublisher
+	interval  time.Duration
+	batchSize int
+	logger    *slog.Logger
+}
+
+func NewOrderScheduler(repo *repository.OrderRepository, events *EventPublisher, interval time.Duration, logger *slog.Logger) *OrderScheduler {
+	return &OrderScheduler{
+		repo:      repo,
+		events:    events,
+		interval:  interval,
+		batchSize: 100,
+		logger:    logger.With("component", "order_scheduler"),
+	}
+}
+
+// Run 阻塞运行直到 ctx 取消
+func (s *OrderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	s.logger.Info("订单调度器已启动", "interval", s.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("订单调度器退出")
+			return
+		case <-ticker.C:
+			s.activateDue(ctx)
+		}
+	}
+}
+
+func (s *OrderScheduler) activateDue(ctx context.Context) {
+	orders, err := s.repo.FindDueScheduled(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "查询到期调度订单失败", logging.Err(err))
+		return
+	}
+	for i := range orders {
+		// 每个订单单独一个请求 ID，随推送消息的 correlation_id 传给下游
+		orderCtx := logging.WithOrderID(logging.WithRequestID(ctx, logging.NewRequestID()), orders[i].OrderID)
+		if err := s.activate(orderCtx, &orders[i]); err != nil {
+			s.logger.ErrorContext(orderCtx, "激活订单失败", logging.Err(err))
+		}
+	}
+}
+
+// activate 激活一个到期订单：状态恢复、暂存状态删除和 order_created 事件在同一事务中写入，
+// 事件由 OutboxRelay 推送，提交后进程退出也不会漏推
+func (s *OrderScheduler) activate(ctx context.Context, order *model.OrderData) error {
+	status, err := pendingStatus(order)
+	if err != nil {
+		return err
+	}
+
+	activated := *order
+	activated.Status = status
+	activated.Metadata = make(model.JSONB, len(order.Metadata))
+	for k, v := range order.Metadata {
+		if k != model.MetadataPendingStatus {
+			activated.Metadata[k] = v
+		}
+	}
+
+	claimed := false
+	err = s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		ok, err := tx.ActivateScheduled(ctx, order.OrderID, status)
+		if err != nil {
+			return fmt.Errorf("更新订单状态失败: %w", err)
+		}
+		if !ok {
+			// 已被其他实例激活或已撤单
+			return nil
+		}
+		claimed = true
+		return s.events.WriteCreated(ctx, tx, []*model.OrderData{&activated}, time.Now())
+	})
+	if err != nil || !claimed {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "调度订单已生效", "status", status,
+		"routing_key", PushRoutingKey(order.ChainIndex, order.EventType))
+	return nil
+}
+
+// pendingStatus 调度前的原始状态。早期写入的订单没有暂存状态，使用上游原始消息中的状态；
+// 两者都没有时不激活，避免把状态改成空值
+func pendingStatus(order *model.OrderData) (string, error) {
+	if status, ok := order.Metadata[model.MetadataPendingStatus].(string); ok {
+		return status, nil
+	}
+	if raw, ok := order.Metadata["order"]; ok {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return "", fmt.Errorf("无法解析原始消息: %w", err)
+		}
+		var msg models.Order
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return "", fmt.Errorf("无法解析原始消息: %w", err)
+		}
+		if msg.OrderInfo.Status != "" && msg.OrderInfo.Status != model.OrderStatusScheduled {
+			return msg.OrderInfo.Status, nil
+		}
+	}
+	return "", errors.New("缺少调度前的原始状态")
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/model"
+)
+
+func TestPendingStatus(t *testing.T) {
+	var msg models.Order
+	msg.OrderInfo.Status = "open"
+	// 从数据库读出的 Metadata 中原始消息是 map
+	var stored map[string]interface{}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	var scheduledMsg models.Order
+	scheduledMsg.OrderInfo.Status = model.OrderStatusScheduled
+
+	tests := []struct {
+		name     string
+		metadata model.JSONB
+		want     string
+		wantErr  bool
+	}{
+		{"暂存状态", model.JSONB{model.MetadataPendingStatus: "open"}, "open", false},
+		// REST 写入的订单原始状态可以为空
+		{"暂存状态为空", model.JSONB{model.MetadataPendingStatus: ""}, "", false},
+		{"原始消息", model.JSONB{"order": msg}, "open", false},
+		{"已入库的原始消息", model.JSONB{"order": stored}, "open", false},
+		{"原始消息状态为 scheduled", model.JSONB{"order": scheduledMsg}, "", true},
+		{"都没有", model.JSONB{}, "", true},
+		{"Metadata 为空", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pendingStatus(&model.OrderData{Status: model.OrderStatusScheduled, Metadata: tt.metadata})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("pendingStatus = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
+	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/metrics"
 	"trade-solution/ordercenter/model"
 	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/tracing"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -21,6 +26,9 @@ func InitDB(dsn string) (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect DB: %w", err)
 	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -34,57 +42,341 @@ func InitDB(dsn string) (*gorm.DB, error) {
 	return db, nil
 }
 
-// MonitorAndReconnectDB 定时检查数据库连接并重连
-func MonitorAndReconnectDB(dsn string, db **gorm.DB, interval time.Duration) {
+// MonitorAndReconnectDB 定时检查数据库连接，失效时重连并原子替换 provider 中的连接。
+// 阻塞运行直到 ctx 取消。
+func MonitorAndReconnectDB(ctx context.Context, dsn string, provider *repository.DBProvider, interval time.Duration, logger *slog.Logger) {
+	logger = logger.With("component", "db_monitor")
 	ticker := time.NewTicker(interval)
-	go func() {
-		for range ticker.C {
-			sqlDB, err := (*db).DB()
-			if sqlDB == nil || sqlDB.Ping() != nil || err != nil {
-				log.Println("⚠️ 数据库连接失效，正在重连...")
-				newDB, err := InitDB(dsn)
-				if err != nil {
-					log.Printf("❌ 重连失败: %v", err)
-				} else {
-					*db = newDB
-					log.Println("✅ 数据库重连成功")
-				}
-			}
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if pingDB(ctx, provider.Get()) == nil {
+			provider.SetHealthy(true)
+			continue
+		}
+
+		provider.SetHealthy(false)
+		logger.Warn("数据库连接失效，正在重连")
+		newDB, err := InitDB(dsn)
+		if err != nil {
+			logger.Error("数据库重连失败", logging.Err(err))
+			continue
+		}
+		if err := pingDB(ctx, newDB); err != nil {
+			logger.Error("数据库重连失败", logging.Err(err))
+			closeDB(newDB)
+			continue
 		}
-	}()
+
+		// 先替换再关闭旧连接，之后的查询都会走新连接
+		closeDB(provider.Swap(newDB))
+		provider.SetHealthy(true)
+		metrics.DBReconnects.Inc()
+		logger.Info("数据库重连成功")
+	}
+}
+
+func pingDB(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(pingCtx)
+}
+
+// closeDB 关闭底层连接池
+func closeDB(db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
 }
 
 type OrderService struct {
 	repo *repository.OrderRepository
+	// 按策略返回订单默认有效期，0 表示不过期
+	orderTTL func(strategyID int64) time.Duration
+	logger   *slog.Logger
+	// 推送新订单和生命周期事件
+	events *EventPublisher
+	// 消费端去重记录的有效期，0 表示不去重
+	inboxTTL time.Duration
+	// 队列消息出现未知字段时拒绝
+	strictIngest bool
+}
+
+type OrderServiceOption func(*OrderService)
+
+// WithOrderTTL 设置订单默认有效期，消息中未带 expires_at 时使用
+func WithOrderTTL(ttl func(strategyID int64) time.Duration) OrderServiceOption {
+	return func(s *OrderService) {
+		s.orderTTL = ttl
+	}
 }
 
-func NewOrderService(repo *repository.OrderRepository) *OrderService {
-	return &OrderService{repo: repo}
+// WithEventPublisher 设置推送订单事件使用的 EventPublisher
+func WithEventPublisher(events *EventPublisher) OrderServiceOption {
+	return func(s *OrderService) {
+		s.events = events
+	}
+}
+
+// WithStrictIngest 开启后队列消息中出现未知字段直接进入死信队列，不写入数据库
+func WithStrictIngest(strict bool) OrderServiceOption {
+	return func(s *OrderService) {
+		s.strictIngest = strict
+	}
+}
+
+// WithInboxTTL 设置消费端去重记录的有效期，有效期内重复投递的消息直接确认
+func WithInboxTTL(ttl time.Duration) OrderServiceOption {
+	return func(s *OrderService) {
+		s.inboxTTL = ttl
+	}
+}
+
+// WithLogger 设置日志，默认使用 slog.Default()
+func WithLogger(logger *slog.Logger) OrderServiceOption {
+	return func(s *OrderService) {
+		s.logger = logger
+	}
 }
 
-func (s *OrderService) CreateOrder(order *model.OrderData) error {
-	existing, err := s.repo.GetByID(order.OrderID)
+func NewOrderService(repo *repository.OrderRepository, opts ...OrderServiceOption) *OrderService {
+	s := &OrderService{repo: repo, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ErrOrderExists 订单已存在
+var ErrOrderExists = errors.New("订单已存在，order_id 重复")
+
+// CreateAndPublishOrder 创建订单并在同一事务中写入推送事件，REST 和队列写入共用。
+// 返回 nil 时订单和事件都已提交，事件由 OutboxRelay 推送到 order_event_exchange；
+// 未到生效时间的订单只入库，由调度器到期后推送。
+func (s *OrderService) CreateAndPublishOrder(ctx context.Context, order *model.OrderData) error {
+	if err := s.CreateOrder(ctx, order); err != nil {
+		return err
+	}
+	s.logCreated(logging.WithOrderID(ctx, order.OrderID), order)
+	return nil
+}
+
+func (s *OrderService) logCreated(ctx context.Context, order *model.OrderData) {
+	s.logger.InfoContext(ctx, "成功写入订单", "user_id", order.UserID, "strategy_id", order.StrategyID)
+	if order.Status == model.OrderStatusScheduled {
+		s.logger.InfoContext(ctx, "订单已调度", "activate_at", order.ActivateAt.Format(time.RFC3339))
+	}
+}
+
+func (s *OrderService) CreateOrder(ctx context.Context, order *model.OrderData) error {
+	existing, err := s.repo.GetByID(ctx, order.OrderID)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("检查订单失败: %w", err)
 	}
 	if existing != nil && existing.OrderID != "" {
-		return errors.New("订单已存在，order_id 重复")
+		return ErrOrderExists
 	}
-	return s.repo.Create(order)
+	s.prepareNewOrder(order, time.Now())
+	return s.insertOrder(ctx, order)
 }
 
-func (s *OrderService) GetOrderByID(orderID string) (*model.OrderData, error) {
-	return s.repo.GetByID(orderID)
+// insertOrder 写入订单和 order_created 事件，队列消息在同一事务中登记到收件箱。
+// 检查之后被并发写入的订单同样返回 ErrOrderExists。
+func (s *OrderService) insertOrder(ctx context.Context, order *model.OrderData) error {
+	return s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		if err := s.claimMessage(ctx, tx); err != nil {
+			return err
+		}
+		if err := tx.Create(ctx, order); err != nil {
+			if repository.IsDuplicateKey(err) {
+				return ErrOrderExists
+			}
+			return err
+		}
+		return s.events.WriteCreated(ctx, tx, []*model.OrderData{order}, time.Now())
+	})
+}
+
+// prepareNewOrder 入库前补齐默认有效期和推送内容，生效时间在未来的订单置为 scheduled
+func (s *OrderService) prepareNewOrder(order *model.OrderData, now time.Time) {
+	s.applyDefaultExpiry(order, now)
+	// 推送内容要在 scheduleOrder 之前生成，保留订单原始状态
+	if order.Metadata == nil {
+		order.Metadata = model.JSONB{}
+	}
+	if _, ok := order.Metadata["order"]; !ok {
+		order.Metadata["order"] = orderEnvelope(order)
+	}
+	scheduleOrder(order, now)
+}
+
+// orderEnvelope 由订单数据重建上游消息格式，REST 创建的订单按同样的格式推送
+func orderEnvelope(order *model.OrderData) models.Order {
+	return models.Order{
+		OrderInfo: models.OrderInfo{
+			OrderID:        order.OrderID,
+			Status:         order.Status,
+			EventTimestamp: order.EventTimestamp,
+			EventType:      order.EventType,
+		},
+		StrategyInfo: models.StrategyInfo{
+			StrategyId: int(order.StrategyID),
+			UserInfo: models.UserInfo{
+				UserId:       order.UserID,
+				BscPublicKey: order.BscPublicKey,
+				SolPublicKey: order.SolPublicKey,
+			},
+		},
+		ChainInfo: models.ChainInfo{
+			TokenAddress: order.TokenAddress,
+			ChainIndex:   order.ChainIndex,
+		},
+	}
 }
 
-func (s *OrderService) UpdateOrder(orderID string, updated *model.OrderData) error {
-	return s.repo.Update(orderID, updated)
+// applyDefaultExpiry 未指定过期时间时按策略默认有效期计算，从生效时间起算
+func (s *OrderService) applyDefaultExpiry(order *model.OrderData, now time.Time) {
+	if order.ExpiresAt != nil || s.orderTTL == nil {
+		return
+	}
+	ttl := s.orderTTL(order.StrategyID)
+	if ttl <= 0 {
+		return
+	}
+	start := now
+	if order.ActivateAt != nil && order.ActivateAt.After(now) {
+		start = *order.ActivateAt
+	}
+	expiresAt := start.Add(ttl)
+	order.ExpiresAt = &expiresAt
 }
 
-func (s *OrderService) DeleteOrder(orderID string) error {
-	return s.repo.Delete(orderID)
+// scheduleOrder 生效时间在未来的订单先置为 scheduled，原始状态暂存到 Metadata
+func scheduleOrder(order *model.OrderData, now time.Time) {
+	if order.ActivateAt == nil || !order.ActivateAt.After(now) {
+		return
+	}
+	if order.Metadata == nil {
+		order.Metadata = model.JSONB{}
+	}
+	order.Metadata[model.MetadataPendingStatus] = order.Status
+	order.Status = model.OrderStatusScheduled
+}
+
+func (s *OrderService) GetOrderByID(ctx context.Context, orderID string) (*model.OrderData, error) {
+	return s.repo.GetByID(ctx, orderID)
+}
+
+// UpdateOrder 更新订单，历史和 order_updated 事件在同一事务中写入。
+// 调度中的订单保持 scheduled 和原生效时间，修改的状态暂存到 pending_status，生效时再使用。
+// 订单不存在返回 ErrOrderNotFound，已过期或已撤单返回 ErrOrderClosed。
+func (s *OrderService) UpdateOrder(ctx context.Context, orderID string, updated *model.OrderData) error {
+	if err := validateUpdate(updated); err != nil {
+		return err
+	}
+	return s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+		if err := s.claimMessage(ctx, tx); err != nil {
+			return err
+		}
+		locked, err := tx.LockOpenByIDs(ctx, []string{orderID})
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return closedOrMissing(ctx, tx, orderID)
+		}
+		before := &locked[0]
+		after, err := updateLocked(ctx, tx, before, updated)
+		if err != nil {
+			return err
+		}
+		return s.events.WriteLifecycle(ctx, tx, EventOrderUpdated, lifecycleActionUpdated, []LifecycleChange{{Before: before, After: after}}, time.Now())
+	})
 }
 
-func (s *OrderService) GetAllOrders() ([]model.OrderData, error) {
-	return s.repo.GetAll()
+// keepScheduled 返回调度中订单的更新内容：状态保持 scheduled，不修改生效时间，
+// 更新中的状态和原有的 pending_status 写回 Metadata，避免调度器找不到原始状态
+func keepScheduled(before, updated *model.OrderData) *model.OrderData {
+	u := *updated
+	pending, ok := before.Metadata[model.MetadataPendingStatus]
+	if u.Status != "" && u.Status != model.OrderStatusScheduled {
+		pending, ok = u.Status, true
+	}
+	source := u.Metadata
+	if source == nil {
+		source = before.Metadata
+	}
+	u.Metadata = make(model.JSONB, len(source)+1)
+	for k, v := range source {
+		u.Metadata[k] = v
+	}
+	if ok {
+		u.Metadata[model.MetadataPendingStatus] = pending
+	}
+	u.Status = model.OrderStatusScheduled
+	u.ActivateAt = nil
+	return &u
+}
+
+// DeleteOrder 撤单：订单置为 withdrawn 并保留记录，历史和 order_withdrawn 事件在同一事务中写入。
+// 订单不存在返回 ErrOrderNotFound，已过期或已撤单返回 ErrOrderClosed。
+func (s *OrderService) DeleteOrder(ctx context.Context, orderID string) error {
+	befores, err := s.lockAndWithdraw(ctx, []string{orderID})
+	if err != nil {
+		return err
+	}
+	if len(befores) == 0 {
+		return closedOrMissing(ctx, s.repo, orderID)
+	}
+	return nil
+}
+
+func (s *OrderService) GetAllOrders(ctx context.Context, filter model.OrderFilter) ([]model.OrderData, error) {
+	return s.repo.GetAll(ctx, filter)
+}
+
+// ErrInvalidQuery 查询参数无效，接口返回 400
+var ErrInvalidQuery = errors.New("查询参数无效")
+
+// GetOrderStats 按分组字段和时间桶统计订单数
+func (s *OrderService) GetOrderStats(ctx context.Context, q model.OrderStatsQuery) ([]model.OrderStatsRow, error) {
+	seen := make(map[string]bool, len(q.GroupBy))
+	for _, col := range q.GroupBy {
+		if !repository.IsStatsGroupColumn(col) {
+			return nil, fmt.Errorf("%w: 不支持的分组字段 %s", ErrInvalidQuery, col)
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("%w: 分组字段重复 %s", ErrInvalidQuery, col)
+		}
+		seen[col] = true
+	}
+	if q.Interval != "" {
+		if !repository.IsStatsInterval(q.Interval) {
+			return nil, fmt.Errorf("%w: 不支持的时间粒度 %s", ErrInvalidQuery, q.Interval)
+		}
+		if q.TimeField == "" {
+			q.TimeField = model.StatsTimeCreatedAt
+		}
+		if !repository.IsStatsTimeField(q.TimeField) {
+			return nil, fmt.Errorf("%w: 不支持的时间字段 %s", ErrInvalidQuery, q.TimeField)
+		}
+	}
+	rows, err := s.repo.CountGrouped(ctx, q)
+	if errors.Is(err, repository.ErrStatsTooManyRows) {
+		return nil, fmt.Errorf("%w: %w，请缩小查询范围或减少分组字段", ErrInvalidQuery, err)
+	}
+	return rows, err
 }
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestKeepScheduled(t *testing.T) {
+	activateAt := time.Now().Add(time.Hour)
+	later := activateAt.Add(time.Hour)
+	before := &model.OrderData{
+		OrderID:    "o-1",
+		Status:     model.OrderStatusScheduled,
+		ActivateAt: &activateAt,
+		Metadata:   model.JSONB{model.MetadataPendingStatus: "open", "order": "raw"},
+	}
+	tests := []struct {
+		name         string
+		updated      *model.OrderData
+		wantMetadata model.JSONB
+	}{
+		{"只改其他字段", &model.OrderData{TokenAddress: "0xdef"},
+			model.JSONB{model.MetadataPendingStatus: "open", "order": "raw"}},
+		{"修改状态", &model.OrderData{Status: "paused"},
+			model.JSONB{model.MetadataPendingStatus: "paused", "order": "raw"}},
+		// 替换 Metadata 时保留暂存的原始状态
+		{"替换 Metadata", &model.OrderData{Metadata: model.JSONB{"order": "new"}},
+			model.JSONB{model.MetadataPendingStatus: "open", "order": "new"}},
+		{"修改生效时间", &model.OrderData{ActivateAt: &later},
+			model.JSONB{model.MetadataPendingStatus: "open", "order": "raw"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keepScheduled(before, tt.updated)
+			if got.Status != model.OrderStatusScheduled || got.ActivateAt != nil {
+				t.Fatalf("status = %q, activate_at = %v", got.Status, got.ActivateAt)
+			}
+			if !reflect.DeepEqual(got.Metadata, tt.wantMetadata) {
+				t.Fatalf("metadata = %v, want %v", got.Metadata, tt.wantMetadata)
+			}
+		})
+	}
+	if before.Metadata[model.MetadataPendingStatus] != "open" || before.Metadata["order"] != "raw" {
+		t.Fatalf("修改了原订单的 Metadata: %v", before.Metadata)
+	}
+}
+
+// pendingStatusArg 匹配 Metadata 中 pending_status 为指定值的参数
+type pendingStatusArg string
+
+func (a pendingStatusArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	var metadata model.JSONB
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return false
+	}
+	return metadata[model.MetadataPendingStatus] == string(a)
+}
+
+// 更新调度中的订单后仍为 scheduled 且保留生效时间和 pending_status，调度器到期后照常激活
+func TestUpdateScheduledOrder(t *testing.T) {
+	srv, mock := newMockService(t)
+	activateAt := time.Now().Add(time.Hour)
+	columns := []string{"order_id", "status", "token_address", "activate_at", "metadata"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .*`order_data`.* FOR UPDATE").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("o-1", model.OrderStatusScheduled, "0xabc", activateAt, []byte(`{"pending_status":"open"}`)))
+	// 只更新这几列，activate_at 不在其中
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_data` SET `status`=?,`token_address`=?,`metadata`=?,`updated_at`=? WHERE order_id = ?")).
+		WithArgs(model.OrderStatusScheduled, "0xdef", pendingStatusArg("paused"), sqlmock.AnyArg(), "o-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT .*`order_data`").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("o-1", model.OrderStatusScheduled, "0xdef", activateAt, []byte(`{"pending_status":"paused"}`)))
+	mock.ExpectExec("INSERT INTO `order_history`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `event_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := srv.UpdateOrder(context.Background(), "o-1", &model.OrderData{Status: "paused", TokenAddress: "0xdef"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
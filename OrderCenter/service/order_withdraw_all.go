@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+)
+
+// 批量撤单每个事务处理的订单数
+const withdrawAllBatchSize = 500
+
+// WithdrawAllResult 批量撤单结果
+type WithdrawAllResult struct {
+	Withdrawn int `json:"withdrawn"`
+}
+
+// WithdrawAll 撤掉范围内所有未进入终态的订单。
+// 分批在事务中锁定订单、置为 withdrawn 并写入历史和撤单事件，事件由 OutboxRelay 推送。
+// 重复执行是安全的：已撤掉的订单不会再次匹配。
+func (s *OrderService) WithdrawAll(ctx context.Context, scope model.WithdrawScope) (*WithdrawAllResult, error) {
+	if scope.Empty() {
+		return nil, fmt.Errorf("%w: 至少指定一个撤单条件", ErrInvalidBatch)
+	}
+
+	// 分多个事务执行，消息在最后一个事务中登记；已处理过的消息在开始前跳过
+	processed, err := s.messageProcessed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if processed {
+		return nil, ErrMessageProcessed
+	}
+
+	res := &WithdrawAllResult{}
+	for {
+		var withdrawn []model.OrderData
+		err := s.repo.Transaction(ctx, func(tx *repository.OrderRepository) error {
+			orders, err := tx.LockOpenByScope(ctx, scope, withdrawAllBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(orders) < withdrawAllBatchSize {
+				if err := s.claimMessage(ctx, tx); err != nil {
+					return err
+				}
+			}
+			if len(orders) == 0 {
+				return nil
+			}
+			if err := withdrawLocked(ctx, tx, orders); err != nil {
+				return err
+			}
+			if err := s.events.WriteLifecycle(ctx, tx, EventOrderWithdrawn, lifecycleActionWithdrawn, withdrawnChanges(orders), time.Now()); err != nil {
+				return err
+			}
+			withdrawn = orders
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("批量撤单失败: %w", err)
+		}
+		if len(withdrawn) == 0 {
+			break
+		}
+		res.Withdrawn += len(withdrawn)
+		if len(withdrawn) < withdrawAllBatchSize {
+			break
+		}
+	}
+
+	s.logger.InfoContext(ctx, "批量撤单完成", "scope", scope, "withdrawn", res.Withdrawn)
+	return res, nil
+}
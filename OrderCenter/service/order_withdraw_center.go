@@ -2,115 +2,134 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"trade-solution/common/go/lib/models"
+	"trade-solution/ordercenter/logging"
 	"trade-solution/ordercenter/model"
-	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/pb"
 	"trade-solution/ordercenter/utils"
 
+	"github.com/streadway/amqp"
+	"google.golang.org/protobuf/proto"
 	"gorm.io/gorm"
 )
 
-// 并发 worker
-func StartWithdrawConsumer(queueName string, rmq *utils.RabbitMQ, workerCount int, prefetch int, db *gorm.DB) error {
-	// 设置 QoS：每个 worker 最多同时处理 prefetch 条未确认的消息
-	if err := rmq.Channel.Qos(prefetch, 0, false); err != nil {
-		return fmt.Errorf("设置 Qos 失败: %w", err)
-	}
+// 撤单队列的消息类型，不带 type 的消息按单个订单撤单处理
+const withdrawTypeAll = "withdraw_all"
 
-	deliveries, err := rmq.Consume(queueName)
-	if err != nil {
-		return fmt.Errorf("从队列 %s 消费失败: %w", queueName, err)
+// withdrawAllMessage 按范围批量撤单：{"type":"withdraw_all","scope":{"strategy_id":1001}}。
+// protobuf 编码时为 ordercenter.v1.WithdrawAllMessage，不需要 type 字段。
+type withdrawAllMessage struct {
+	Type  string              `json:"type"`
+	Scope model.WithdrawScope `json:"scope"`
+}
+
+func (m *withdrawAllMessage) FromProto(msg proto.Message) error {
+	pm, ok := msg.(*pb.WithdrawAllMessage)
+	if !ok {
+		return fmt.Errorf("批量撤单消息不支持 %s", msg.ProtoReflect().Descriptor().FullName())
 	}
-	log.Printf("📥 开始消费队列: %s，workers=%d prefetch=%d", queueName, workerCount, prefetch)
+	scope := pm.GetScope()
+	m.Type = withdrawTypeAll
+	m.Scope = model.WithdrawScope{UserID: scope.GetUserId(), TokenAddress: scope.GetTokenAddress()}
+	if scope.StrategyId != nil {
+		id := scope.GetStrategyId()
+		m.Scope.StrategyID = &id
+	}
+	if scope.ChainIndex != nil {
+		chain := int(scope.GetChainIndex())
+		m.Scope.ChainIndex = &chain
+	}
+	return nil
+}
 
-	ctx := context.Background()
+// withdrawTypeProbe 只读取消息类型
+type withdrawTypeProbe struct {
+	Type string `json:"type"`
+}
 
-	// 启动多个 worker 并发处理消息
-	for i := 0; i < workerCount; i++ {
-		workerID := i
-		repo := repository.NewOrderRepository(db.Session(&gorm.Session{NewDB: true}))
-		srv := NewOrderService(repo)
-		go func(workerID int, srv *OrderService) {
-			for d := range deliveries {
-				// 处理单条消息
-				if err := withdrawMessage(ctx, d.Body, srv); err != nil {
-					log.Printf("❌ worker-%d 处理消息失败: %v", workerID, err)
-					//// —— 新增 debug 打印 ——
-					//log.Printf("🛠 原始 JSON：%s", d.Body)
-					d.Nack(false, false) // 拒绝并重新入队
-					continue
-				}
-				d.Ack(false) // 成功确认
-			}
-		}(workerID, srv)
+// FromProto protobuf 消息按 content_type 中的消息类型区分
+func (p *withdrawTypeProbe) FromProto(msg proto.Message) error {
+	if _, ok := msg.(*pb.WithdrawAllMessage); ok {
+		p.Type = withdrawTypeAll
 	}
-
 	return nil
 }
 
 // 单条消息处理逻辑
-func withdrawMessage(ctx context.Context, body []byte, srv *OrderService) error {
-	var msg models.Order
-	if err := json.Unmarshal(body, &msg); err != nil {
-		return fmt.Errorf("无法解析消息: %w", err)
+func withdrawMessage(ctx context.Context, d amqp.Delivery, srv *OrderService) error {
+	codec, err := utils.CodecForContentType(d.ContentType)
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	var probe withdrawTypeProbe
+	if err := codec.Unmarshal(d.Body, &probe, false); err != nil {
+		return utils.Permanent(fmt.Errorf("无法解析消息: %w", err))
+	}
+	switch probe.Type {
+	case "":
+	case withdrawTypeAll:
+		var typed withdrawAllMessage
+		if err := codec.Unmarshal(d.Body, &typed, srv.strictIngest); err != nil {
+			return utils.Permanent(fmt.Errorf("无法解析消息: %w", err))
+		}
+		return withdrawAll(ctx, typed.Scope, srv)
+	default:
+		return utils.Permanent(fmt.Errorf("未知的撤单消息类型: %s", probe.Type))
 	}
 
-	orderID := msg.OrderInfo.OrderID
-	if orderID == "" {
-		return fmt.Errorf("无效的订单ID")
+	msg, err := decodeOrderMessage(d, srv.strictIngest)
+	if err != nil {
+		return err
 	}
+	orderID := msg.OrderID
+	ctx = logging.WithOrderID(ctx, orderID)
 
-	// 更新数据库（删除，更新）
+	// 更新数据库（撤单，更新）
 	// 查询订单是否存在
-	existing, err := srv.GetOrderByID(orderID)
+	existing, err := srv.GetOrderByID(ctx, orderID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("⚠️ 撤单忽略：订单 %s 不存在", orderID)
+			srv.logger.WarnContext(ctx, "撤单忽略：订单不存在")
 			return nil // 不算错误
 		}
 		return fmt.Errorf("查询订单失败: %w", err)
 	}
 
-	if msg.OrderInfo.Status == "update" {
-		updateData := &model.OrderData{
-			OrderID:        msg.OrderInfo.OrderID,
-			Status:         msg.OrderInfo.Status,
-			EventTimestamp: msg.OrderInfo.EventTimestamp,
-			EventType:      msg.OrderInfo.EventType,
-
-			StrategyID:   int64(msg.StrategyInfo.StrategyId),
-			UserID:       msg.StrategyInfo.UserInfo.UserId,
-			BscPublicKey: msg.StrategyInfo.UserInfo.BscPublicKey,
-			SolPublicKey: msg.StrategyInfo.UserInfo.SolPublicKey,
-
-			TokenAddress: msg.ChainInfo.TokenAddress,
-			ChainIndex:   msg.ChainInfo.ChainIndex,
-
-			Metadata: model.JSONB{
-				"order": msg,
-			},
-
-			//Metadata: model.JSONB{
-			//	"order": msg,
-			//},
-			//CreatedAt: time.Now(),
-			//UpdatedAt: time.Now(),
-		}
-		if err := srv.UpdateOrder(orderID, updateData); err != nil {
+	if msg.Status == "update" {
+		// 更新只修改订单信息，不修改生效和过期时间
+		msg.ActivateAt, msg.ExpiresAt = nil, nil
+		if err := srv.UpdateOrder(ctx, orderID, msg); err != nil {
+			if errors.Is(err, ErrOrderNotFound) || errors.Is(err, ErrOrderClosed) {
+				srv.logger.WarnContext(ctx, "撤单忽略：订单已被撤掉或已过期")
+				return nil
+			}
+			if errors.Is(err, ErrInvalidUpdate) {
+				return utils.Permanent(err)
+			}
 			return fmt.Errorf("更新订单失败: %w", err)
 		}
-		log.Printf("♻️ 成功更新订单 %s 状态为 %s", orderID, msg.OrderInfo.Status)
+		srv.logger.InfoContext(ctx, "成功更新订单", "status", msg.Status)
 		return nil // 不算错误
 	}
 
-	// 删除
-	if err := srv.DeleteOrder(orderID); err != nil {
-		return fmt.Errorf("删除订单失败: %w", err)
+	// 撤单
+	if err := srv.DeleteOrder(ctx, orderID); err != nil {
+		if errors.Is(err, ErrOrderNotFound) || errors.Is(err, ErrOrderClosed) {
+			srv.logger.WarnContext(ctx, "撤单忽略：订单已被撤掉或已过期")
+			return nil
+		}
+		return fmt.Errorf("撤单失败: %w", err)
 	}
-	log.Printf("🗑️ 成功删除订单：%s (原状态: %s)", orderID, existing.Status)
+	srv.logger.InfoContext(ctx, "成功撤单", "from_status", existing.Status)
 	return nil
 }
+
+// withdrawAll 批量撤单消息，范围为空属于消息错误，不重试
+func withdrawAll(ctx context.Context, scope model.WithdrawScope, srv *OrderService) error {
+	_, err := srv.WithdrawAll(ctx, scope)
+	if errors.Is(err, ErrInvalidBatch) {
+		return utils.Permanent(err)
+	}
+	return err
+}
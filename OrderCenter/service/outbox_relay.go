@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/metrics"
+	"trade-solution/ordercenter/model"
+	"trade-solution/ordercenter/repository"
+	"trade-solution/ordercenter/tracing"
+	"trade-solution/ordercenter/utils"
+
+	"github.com/streadway/amqp"
+)
+
+// 推送失败后的最长退避时间
+const outboxMaxBackoff = 30 * time.Second
+
+// 单条事件等待 broker 确认的最长时间
+const outboxPublishTimeout = 10 * time.Second
+
+// confirmPublisher 发布并等待 broker 确认，由 *utils.RabbitMQ 实现
+type confirmPublisher interface {
+	PublishConfirmed(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error
+}
+
+// OutboxRelay 按写入顺序推送发件箱中的事件，broker 确认后删除。
+// 推送失败时停在失败的那一条并退避重试，后面的事件不会越过它，同一订单的事件保持顺序。
+// 作为单例任务运行；领导权转移时删除会被 fencing token 拒绝，事件由新的主节点再推送一次，
+// 所以下游可能收到重复事件，需按 event_id（消息的 message_id）去重。
+type OutboxRelay struct {
+	repo      *repository.OrderRepository
+	rmq       confirmPublisher
+	interval  time.Duration
+	batchSize int
+	logger    *slog.Logger
+}
+
+func NewOutboxRelay(repo *repository.OrderRepository, rmq *utils.RabbitMQ, interval time.Duration, logger *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		rmq:       rmq,
+		interval:  interval,
+		batchSize: 100,
+		logger:    logger.With("component", "outbox_relay"),
+	}
+}
+
+// Run 阻塞运行直到 ctx 取消
+func (r *OutboxRelay) Run(ctx context.Context) {
+	r.logger.Info("事件发件箱推送已启动", "interval", r.interval)
+	timer := time.NewTimer(r.interval)
+	defer timer.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("事件发件箱推送退出")
+			return
+		case <-timer.C:
+		}
+
+		sent, err := r.relay(ctx)
+		delay := r.interval
+		switch {
+		case err != nil:
+			delay = utils.Backoff(failures, r.interval, outboxMaxBackoff)
+			failures++
+			r.logger.ErrorContext(ctx, "推送发件箱事件失败", "sent", sent, "retry_in", delay, logging.Err(err))
+		case sent == r.batchSize:
+			// 还有积压，立即处理下一批
+			failures, delay = 0, 0
+		default:
+			failures = 0
+		}
+		timer.Reset(delay)
+	}
+}
+
+// relay 推送一批事件，返回成功推送的条数；遇到失败立即返回
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	events, err := r.repo.PendingOutboxEvents(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("查询发件箱失败: %w", err)
+	}
+	if len(events) == 0 {
+		metrics.OutboxOldestAge.Set(0)
+		return 0, nil
+	}
+	metrics.OutboxOldestAge.Set(time.Since(events[0].CreatedAt).Seconds())
+
+	for i := range events {
+		event := &events[i]
+		if err := r.publish(ctx, event); err != nil {
+			if recErr := r.repo.RecordOutboxFailure(ctx, event.ID, err.Error()); recErr != nil {
+				r.logger.ErrorContext(ctx, "记录推送失败原因失败", "event_id", event.EventID, logging.Err(recErr))
+			}
+			return i, fmt.Errorf("推送事件 %s（第 %d 次失败）: %w", event.EventID, event.Attempts+1, err)
+		}
+		if err := r.repo.DeleteOutboxEvent(ctx, event.ID); err != nil {
+			// 已推送但未删除，下一轮会重复推送
+			return i, fmt.Errorf("删除已推送事件 %s 失败: %w", event.EventID, err)
+		}
+	}
+	return len(events), nil
+}
+
+// publish 推送单条事件，带上写入时的请求 ID 和 trace 上下文
+func (r *OutboxRelay) publish(ctx context.Context, event *model.EventOutbox) error {
+	ctx = tracing.ExtractAMQP(ctx, amqp.Table(event.Headers))
+	ctx = logging.WithOrderID(logging.WithRequestID(ctx, event.CorrelationID), event.OrderID)
+	ctx, cancel := context.WithTimeout(ctx, outboxPublishTimeout)
+	defer cancel()
+
+	return r.rmq.PublishConfirmed(ctx, event.Exchange, event.RoutingKey, amqp.Publishing{
+		ContentType:   event.ContentType,
+		CorrelationId: event.CorrelationID,
+		MessageId:     event.EventID,
+		Timestamp:     event.CreatedAt,
+		Type:          event.EventType,
+		Body:          event.Body,
+		DeliveryMode:  amqp.Persistent,
+	})
+}
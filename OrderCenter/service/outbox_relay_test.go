@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/streadway/amqp"
+)
+
+const (
+	pendingOutboxSQL = "SELECT \\* FROM `event_outbox` ORDER BY id LIMIT \\?"
+	deleteOutboxSQL  = "DELETE FROM `event_outbox` WHERE id = \\?"
+	failOutboxSQL    = "UPDATE `event_outbox` SET `attempts`=attempts \\+ 1,`last_error`=\\? WHERE id = \\?"
+)
+
+var outboxColumns = []string{"id", "event_id", "event_type", "order_id", "exchange", "routing_key",
+	"content_type", "body", "correlation_id", "headers", "attempts", "last_error", "created_at"}
+
+// fakeConfirmPublisher 假的确认发布：按顺序返回 results 中的结果，记录发布的消息
+type fakeConfirmPublisher struct {
+	results   []error
+	published []amqp.Publishing
+}
+
+func (p *fakeConfirmPublisher) PublishConfirmed(_ context.Context, _, _ string, msg amqp.Publishing) error {
+	err := p.results[len(p.published)]
+	p.published = append(p.published, msg)
+	return err
+}
+
+func newMockRelay(t *testing.T, results ...error) (*OutboxRelay, *fakeConfirmPublisher, sqlmock.Sqlmock) {
+	t.Helper()
+	provider, mock := newMockProvider(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	relay := NewOutboxRelay(repository.NewOrderRepository(provider), nil, time.Second, logger)
+	pub := &fakeConfirmPublisher{results: results}
+	relay.rmq = pub
+	return relay, pub, mock
+}
+
+// pendingRows 发件箱中待推送的事件，attempts 为已失败的次数
+func pendingRows(attempts int, ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows(outboxColumns)
+	for _, id := range ids {
+		rows.AddRow(id, fmt.Sprintf("e-%d", id), EventOrderUpdated, "o-1", pushExchange, "order.56.buy.updated",
+			"application/json", []byte(`{}`), "req-1", []byte(`{}`), attempts, "", time.Now())
+	}
+	return rows
+}
+
+func expectOutboxDelete(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec(deleteOutboxSQL).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// broker 确认后才删除事件
+func TestOutboxRelayConfirm(t *testing.T) {
+	relay, pub, mock := newMockRelay(t, nil, nil)
+	mock.ExpectQuery(pendingOutboxSQL).WithArgs(relay.batchSize).WillReturnRows(pendingRows(0, 1, 2))
+	expectOutboxDelete(mock, 1)
+	expectOutboxDelete(mock, 2)
+
+	sent, err := relay.relay(context.Background())
+	if err != nil || sent != 2 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+	for i, msg := range pub.published {
+		if msg.MessageId != fmt.Sprintf("e-%d", i+1) || msg.CorrelationId != "req-1" || msg.DeliveryMode != amqp.Persistent {
+			t.Fatalf("第 %d 条: %+v", i, msg)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 未确认的事件记录失败原因并保留，后面的事件不越过它，下一轮从同一条重试
+func TestOutboxRelayRetry(t *testing.T) {
+	nack := errors.New("broker 拒绝了消息")
+	relay, pub, mock := newMockRelay(t, nack, nil, nil)
+
+	mock.ExpectQuery(pendingOutboxSQL).WithArgs(relay.batchSize).WillReturnRows(pendingRows(0, 1, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(failOutboxSQL).WithArgs(nack.Error(), int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	sent, err := relay.relay(context.Background())
+	if !errors.Is(err, nack) || sent != 0 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+	if len(pub.published) != 1 {
+		t.Fatalf("失败后继续推送了后面的事件: %d 条", len(pub.published))
+	}
+
+	mock.ExpectQuery(pendingOutboxSQL).WithArgs(relay.batchSize).WillReturnRows(pendingRows(1, 1, 2))
+	expectOutboxDelete(mock, 1)
+	expectOutboxDelete(mock, 2)
+
+	sent, err = relay.relay(context.Background())
+	if err != nil || sent != 2 {
+		t.Fatalf("重试 sent = %d, err = %v", sent, err)
+	}
+	if pub.published[1].MessageId != "e-1" {
+		t.Fatalf("重试推送了 %s, want e-1", pub.published[1].MessageId)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 已确认但删除失败的事件保留在发件箱，下一轮重复推送
+func TestOutboxRelayDeleteFailure(t *testing.T) {
+	relay, pub, mock := newMockRelay(t, nil, nil)
+	mock.ExpectQuery(pendingOutboxSQL).WithArgs(relay.batchSize).WillReturnRows(pendingRows(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `event_outbox`")).WillReturnError(errors.New("连接断开"))
+	mock.ExpectRollback()
+	mock.ExpectQuery(pendingOutboxSQL).WithArgs(relay.batchSize).WillReturnRows(pendingRows(0, 1))
+	expectOutboxDelete(mock, 1)
+
+	if sent, err := relay.relay(context.Background()); err == nil || sent != 0 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+	if sent, err := relay.relay(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("重试 sent = %d, err = %v", sent, err)
+	}
+	if len(pub.published) != 2 || pub.published[0].MessageId != pub.published[1].MessageId {
+		t.Fatalf("published = %+v", pub.published)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"trade-solution/ordercenter/logging"
+)
+
+// shutdownHooks 停机时依次关闭的组件
+type shutdownHooks struct {
+	// 停止接收新请求并等待进行中的请求结束
+	stopHTTP func(ctx context.Context) error
+	// 消费者随信号 ctx 取消，worker 处理并确认完当前消息后关闭
+	consumersDone <-chan struct{}
+	// 单例任务退出并释放租约后关闭
+	jobsDone <-chan struct{}
+	// 等待进行中的发布
+	waitPublishes func(ctx context.Context) error
+	// 关闭 RabbitMQ 连接，重连监听退出后 monitorDone 关闭
+	closeConn   func()
+	monitorDone <-chan struct{}
+	closeDB     func() error
+	// 导出剩余的 span
+	shutdownTracing func(ctx context.Context) error
+}
+
+// shutdownStep 停机的一个步骤
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// steps 停机顺序：先停止接收请求和消息，等处理中的消息、单例任务和发布都结束后再关闭连接，最后导出 span
+func (h shutdownHooks) steps() []shutdownStep {
+	return []shutdownStep{
+		{"HTTP 服务", h.stopHTTP},
+		{"消费者", func(ctx context.Context) error { return waitDone(ctx, h.consumersDone) }},
+		{"后台任务", func(ctx context.Context) error { return waitDone(ctx, h.jobsDone) }},
+		{"发布", h.waitPublishes},
+		{"RabbitMQ 连接", func(ctx context.Context) error {
+			h.closeConn()
+			return waitDone(ctx, h.monitorDone)
+		}},
+		{"数据库", func(context.Context) error { return h.closeDB() }},
+		{"链路追踪", h.shutdownTracing},
+	}
+}
+
+// runShutdown 按顺序执行停机步骤，所有步骤共用 ctx 的期限。
+// 某一步失败或超时只记录日志，后面的步骤照常执行，保证连接最终都会关闭。
+func runShutdown(ctx context.Context, logger *slog.Logger, steps []shutdownStep) {
+	for _, step := range steps {
+		if err := step.run(ctx); err != nil {
+			logger.Error("停止失败", "component", step.name, logging.Err(err))
+			continue
+		}
+		logger.Info("已停止", "component", step.name)
+	}
+}
+
+// waitDone 等待 done 关闭，超过停机期限时放弃等待
+func waitDone(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待停止超时: %w", ctx.Err())
+	}
+}
+
+func waitGroupDone(wg *sync.WaitGroup) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shutdownRecorder 记录各组件的关闭顺序
+type shutdownRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *shutdownRecorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// closeAfter delay 之后记录 event 并关闭返回的 channel
+func (r *shutdownRecorder) closeAfter(delay time.Duration, event string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(delay)
+		r.add(event)
+		close(done)
+	}()
+	return done
+}
+
+func (r *shutdownRecorder) hooks(consumersDone, jobsDone <-chan struct{}) shutdownHooks {
+	monitorDone := make(chan struct{})
+	return shutdownHooks{
+		stopHTTP:      func(context.Context) error { r.add("http"); return nil },
+		consumersDone: consumersDone,
+		jobsDone:      jobsDone,
+		waitPublishes: func(context.Context) error { r.add("publishes"); return nil },
+		closeConn: func() {
+			r.add("close_conn")
+			close(monitorDone)
+		},
+		monitorDone:     monitorDone,
+		closeDB:         func() error { r.add("db"); return nil },
+		shutdownTracing: func(context.Context) error { r.add("tracing"); return nil },
+	}
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// 连接要等消费者处理完、单例任务退出、发布结束之后才关闭
+func TestShutdownOrder(t *testing.T) {
+	r := &shutdownRecorder{}
+	hooks := r.hooks(r.closeAfter(20*time.Millisecond, "consumers"), r.closeAfter(40*time.Millisecond, "jobs"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	runShutdown(ctx, discardLogger, hooks.steps())
+
+	want := []string{"http", "consumers", "jobs", "publishes", "close_conn", "db", "tracing"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("停机顺序 = %v, want %v", r.events, want)
+	}
+}
+
+// 超过停机期限时放弃等待，后面的步骤照常执行
+func TestShutdownTimeout(t *testing.T) {
+	r := &shutdownRecorder{}
+	// 消费者一直不退出，单例任务已退出
+	jobsDone := make(chan struct{})
+	close(jobsDone)
+	hooks := r.hooks(make(chan struct{}), jobsDone)
+	hooks.waitPublishes = func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("等待发布完成超时")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	runShutdown(ctx, discardLogger, hooks.steps())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("停机耗时 %v，没有按期限放弃等待", elapsed)
+	}
+
+	want := []string{"http", "close_conn", "db", "tracing"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("停机顺序 = %v, want %v", r.events, want)
+	}
+}
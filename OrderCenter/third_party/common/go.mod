@@ -0,0 +1,3 @@
+module trade-solution/common
+
+go 1.24
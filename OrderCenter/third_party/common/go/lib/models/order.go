@@ -0,0 +1,32 @@
+// Package models 是 common 库 models 包的副本，只保留订单中心用到的订单消息类型，
+// 让订单中心可以脱离 monorepo 单独构建和测试。修改字段时需与 common 库保持一致。
+package models
+
+type UserInfo struct {
+	UserId       string `json:"userId"`
+	BscPublicKey string `json:"bscPublicKey"`
+	SolPublicKey string `json:"solPublicKey"`
+}
+
+type StrategyInfo struct {
+	StrategyId int      `json:"strategyId"`
+	UserInfo   UserInfo `json:"userInfo"`
+}
+
+type ChainInfo struct {
+	TokenAddress string `json:"tokenAddress"`
+	ChainIndex   int    `json:"chainIndex"`
+}
+
+type OrderInfo struct {
+	OrderID        string `json:"orderId"`
+	Status         string `json:"status"`
+	EventTimestamp int64  `json:"eventTimestamp"`
+	EventType      string `json:"eventType"`
+}
+
+type Order struct {
+	OrderInfo    OrderInfo    `json:"orderInfo"`
+	StrategyInfo StrategyInfo `json:"strategyInfo"`
+	ChainInfo    ChainInfo    `json:"chainInfo"`
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff 第 attempt 次（从 0 开始）重试前的等待时间：
+// 以 min 为基数指数增长，不超过 max，并在 [d/2, d) 区间内随机抖动，避免多个实例同时重连
+func Backoff(attempt int, min, max time.Duration) time.Duration {
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
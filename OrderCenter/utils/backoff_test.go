@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	min, max := 100*time.Millisecond, 2*time.Second
+	tests := []struct {
+		attempt int
+		// 抖动前的等待时间，结果落在 [base/2, base)
+		base time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, 1600 * time.Millisecond},
+		{5, max},
+		{100, max},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			got := Backoff(tt.attempt, min, max)
+			if got < tt.base/2 || got >= tt.base {
+				t.Fatalf("Backoff(%d) = %v, want [%v, %v)", tt.attempt, got, tt.base/2, tt.base)
+			}
+		}
+	}
+}
+
+func TestBackoffTiny(t *testing.T) {
+	// 基数太小无法抖动时原样返回
+	if got := Backoff(0, time.Nanosecond, time.Second); got != time.Nanosecond {
+		t.Fatalf("Backoff = %v, want 1ns", got)
+	}
+	if got := Backoff(3, 0, time.Second); got != 0 {
+		t.Fatalf("Backoff = %v, want 0", got)
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"trade-solution/ordercenter/config"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 消息体的 content_type
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeMsgpack = "application/msgpack"
+)
+
+// ErrUnsupportedContentType 没有对应 content_type 的编解码器
+var ErrUnsupportedContentType = errors.New("不支持的 content_type")
+
+// Codec 按 content_type 编解码消息体。
+// JSON 和 msgpack 沿用结构体的 json tag 作为字段名，同一个结构体可以在两种编码间切换；
+// protobuf 的字段由 .proto 定义，见 codec_protobuf.go。
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal strict 为 true 时消息中出现目标结构体没有的字段返回错误
+	Unmarshal(data []byte, v interface{}, strict bool) error
+}
+
+var (
+	jsonCodec    Codec = jsonCodecImpl{}
+	msgpackCodec Codec = msgpackCodecImpl{}
+)
+
+// CodecForContentType 按消息的 content_type 选择编解码器，未设置时按 JSON 处理
+func CodecForContentType(contentType string) (Codec, error) {
+	if contentType == "" {
+		return jsonCodec, nil
+	}
+	// 除 protobuf 的 proto 参数外，忽略 charset 等参数
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return jsonCodec, nil
+	case ContentTypeMsgpack, "application/x-msgpack":
+		return msgpackCodec, nil
+	case ContentTypeProtobuf, "application/protobuf":
+		codec, err := protobufCodecFor(params[protoTypeParam])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrUnsupportedContentType, contentType, err)
+		}
+		return codec, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+}
+
+// CodecForExchange 按拓扑中交换机的 encoding 选择发布编码，未设置时使用 JSON。
+// protobuf 编码按 proto_message 指定的消息类型发布。
+func CodecForExchange(ex config.ExchangeConfig) (Codec, error) {
+	switch ex.Encoding {
+	case "", config.EncodingJSON:
+		return jsonCodec, nil
+	case config.EncodingMsgpack:
+		return msgpackCodec, nil
+	case config.EncodingProtobuf:
+		return protobufCodecFor(ex.ProtoMessage)
+	}
+	return nil, fmt.Errorf("不支持的编码: %q", ex.Encoding)
+}
+
+type jsonCodecImpl struct{}
+
+func (jsonCodecImpl) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodecImpl) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodecImpl) Unmarshal(data []byte, v interface{}, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+type msgpackCodecImpl struct{}
+
+func (msgpackCodecImpl) ContentType() string { return ContentTypeMsgpack }
+
+func (msgpackCodecImpl) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodecImpl) Unmarshal(data []byte, v interface{}, strict bool) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if strict {
+		dec.DisallowUnknownFields(true)
+	}
+	return dec.Decode(v)
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ContentTypeProtobuf Protobuf 消息的 content_type，proto 参数指定消息类型，
+// 例如 application/x-protobuf; proto=ordercenter.v1.OrderMessage
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// content_type 中指定 Protobuf 消息类型的参数
+const protoTypeParam = "proto"
+
+// ProtoMarshaler 可以转换为 Protobuf 消息的类型，按 protobuf 编码发布时使用
+type ProtoMarshaler interface {
+	ToProto() proto.Message
+}
+
+// ProtoUnmarshaler 可以由 Protobuf 消息填充的类型，解码 protobuf 消息时使用。
+// m 为 content_type 中指定类型的消息，类型不支持时返回错误。
+type ProtoUnmarshaler interface {
+	FromProto(m proto.Message) error
+}
+
+// protobufCodec 按固定的消息类型编解码。
+// 与 JSON 和 msgpack 不同，字段由 .proto 定义而不是 json tag，
+// 目标类型需要本身是生成的消息，或实现 ProtoMarshaler / ProtoUnmarshaler。
+type protobufCodec struct {
+	msgType protoreflect.MessageType
+}
+
+// protobufCodecFor 按消息全名查找已注册的消息类型，生成代码所在的包需要被导入
+func protobufCodecFor(name string) (Codec, error) {
+	if name == "" {
+		return nil, errors.New("未指定 Protobuf 消息类型")
+	}
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("未注册的 Protobuf 消息类型 %q", name)
+	}
+	return protobufCodec{msgType: msgType}, nil
+}
+
+func (c protobufCodec) name() protoreflect.FullName {
+	return c.msgType.Descriptor().FullName()
+}
+
+func (c protobufCodec) ContentType() string {
+	return mime.FormatMediaType(ContentTypeProtobuf, map[string]string{protoTypeParam: string(c.name())})
+}
+
+func (c protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	var m proto.Message
+	switch x := v.(type) {
+	case proto.Message:
+		m = x
+	case ProtoMarshaler:
+		m = x.ToProto()
+	default:
+		return nil, fmt.Errorf("%T 不能按 Protobuf 编码", v)
+	}
+	if got := m.ProtoReflect().Descriptor().FullName(); got != c.name() {
+		return nil, fmt.Errorf("%T 编码为 %s，与交换机配置的 %s 不一致", v, got, c.name())
+	}
+	return proto.Marshal(m)
+}
+
+func (c protobufCodec) Unmarshal(data []byte, v interface{}, strict bool) error {
+	m := c.msgType.New().Interface()
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	if strict && hasUnknownFields(m.ProtoReflect()) {
+		return fmt.Errorf("%s 消息中有未定义的字段", c.name())
+	}
+	switch x := v.(type) {
+	case ProtoUnmarshaler:
+		return x.FromProto(m)
+	case proto.Message:
+		if got := x.ProtoReflect().Descriptor().FullName(); got != c.name() {
+			return fmt.Errorf("不能把 %s 解码到 %s", c.name(), got)
+		}
+		proto.Reset(x)
+		proto.Merge(x, m)
+		return nil
+	}
+	return fmt.Errorf("%T 不能按 Protobuf 解码", v)
+}
+
+// hasUnknownFields 消息或其嵌套消息中是否有 .proto 中未定义的字段，对应 JSON 的未知字段
+func hasUnknownFields(m protoreflect.Message) bool {
+	if len(m.GetUnknown()) > 0 {
+		return true
+	}
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len() && !found; i++ {
+				found = hasUnknownFields(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				found = hasUnknownFields(mv.Message())
+				return !found
+			})
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			found = hasUnknownFields(v.Message())
+		}
+		return !found
+	})
+	return found
+}
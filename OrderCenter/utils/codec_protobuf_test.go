@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+	"trade-solution/ordercenter/pb"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func mustProtobufCodec(t *testing.T, name string) Codec {
+	t.Helper()
+	codec, err := protobufCodecFor(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func testProtoOrder() *pb.OrderMessage {
+	return &pb.OrderMessage{
+		OrderInfo:     &pb.OrderInfo{OrderId: "o-1", Status: "open", EventTimestamp: 1<<62 + 1},
+		ChainInfo:     &pb.ChainInfo{TokenAddress: "0xabc", ChainIndex: 56},
+		ActivateAt:    1700000000000,
+		SchemaVersion: proto.Int32(1),
+	}
+}
+
+// protoOrderTarget 通过 ProtoUnmarshaler 接收解码结果
+type protoOrderTarget struct {
+	orderID string
+}
+
+func (o *protoOrderTarget) FromProto(m proto.Message) error {
+	o.orderID = m.(*pb.OrderMessage).GetOrderInfo().GetOrderId()
+	return nil
+}
+
+// protoOrderSource 通过 ProtoMarshaler 提供编码内容
+type protoOrderSource struct{}
+
+func (protoOrderSource) ToProto() proto.Message { return testProtoOrder() }
+
+func TestProtobufCodecRoundTrip(t *testing.T) {
+	codec := mustProtobufCodec(t, "ordercenter.v1.OrderMessage")
+	want := testProtoOrder()
+	for _, v := range []interface{}{want, protoOrderSource{}} {
+		data, err := codec.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%T): %v", v, err)
+		}
+		got := &pb.OrderMessage{}
+		if err := codec.Unmarshal(data, got, true); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if !proto.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		var target protoOrderTarget
+		if err := codec.Unmarshal(data, &target, true); err != nil || target.orderID != "o-1" {
+			t.Fatalf("FromProto: order_id = %q, err = %v", target.orderID, err)
+		}
+	}
+}
+
+func TestProtobufCodecMismatch(t *testing.T) {
+	codec := mustProtobufCodec(t, "ordercenter.v1.OrderEvent")
+	if _, err := codec.Marshal(testProtoOrder()); err == nil {
+		t.Error("消息类型与 codec 不一致时应返回错误")
+	}
+	if _, err := codec.Marshal(codecSample{OrderID: "o-1"}); err == nil {
+		t.Error("普通结构体应返回错误")
+	}
+	data, err := proto.Marshal(&pb.OrderEvent{EventId: "e-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.Unmarshal(data, &pb.OrderMessage{}, false); err == nil {
+		t.Error("解码到不同的消息类型时应返回错误")
+	}
+	var sample codecSample
+	if err := codec.Unmarshal(data, &sample, false); err == nil {
+		t.Error("解码到普通结构体时应返回错误")
+	}
+}
+
+func TestProtobufCodecStrict(t *testing.T) {
+	codec := mustProtobufCodec(t, "ordercenter.v1.OrderMessage")
+	data, err := proto.Marshal(testProtoOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 顶层追加一个 .proto 中没有的字段 99
+	unknown := protowire.AppendTag(append([]byte(nil), data...), 99, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, 1)
+	// 嵌套的 OrderInfo 中追加未知字段
+	info, err := proto.Marshal(&pb.OrderInfo{OrderId: "o-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = protowire.AppendTag(info, 99, protowire.VarintType)
+	info = protowire.AppendVarint(info, 1)
+	nested := protowire.AppendTag(nil, 1, protowire.BytesType)
+	nested = protowire.AppendBytes(nested, info)
+
+	for name, body := range map[string][]byte{"顶层": unknown, "嵌套": nested} {
+		if err := codec.Unmarshal(body, &pb.OrderMessage{}, false); err != nil {
+			t.Errorf("%s: 非严格模式应忽略未知字段: %v", name, err)
+		}
+		if err := codec.Unmarshal(body, &pb.OrderMessage{}, true); err == nil {
+			t.Errorf("%s: 严格模式应拒绝未知字段", name)
+		}
+	}
+	if err := codec.Unmarshal(data, &pb.OrderMessage{}, true); err != nil {
+		t.Errorf("没有未知字段时严格模式应通过: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"trade-solution/ordercenter/config"
+)
+
+type codecSample struct {
+	OrderID string            `json:"order_id"`
+	Amount  int64             `json:"amount"`
+	Tags    []string          `json:"tags,omitempty"`
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+type codecSampleNarrow struct {
+	OrderID string `json:"order_id"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	samples := []codecSample{
+		{OrderID: "o-1"},
+		// 超过 2^53 的整数在两种编码下都不能丢精度
+		{OrderID: "o-2", Amount: 1<<62 + 1, Tags: []string{"a", "b"}, Extra: map[string]string{"k": "v"}},
+	}
+	for _, codec := range []Codec{jsonCodec, msgpackCodec} {
+		for _, want := range samples {
+			t.Run(codec.ContentType()+"/"+want.OrderID, func(t *testing.T) {
+				data, err := codec.Marshal(want)
+				if err != nil {
+					t.Fatalf("Marshal: %v", err)
+				}
+				var got codecSample
+				if err := codec.Unmarshal(data, &got, true); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("got %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecStrict(t *testing.T) {
+	for _, codec := range []Codec{jsonCodec, msgpackCodec} {
+		data, err := codec.Marshal(codecSample{OrderID: "o-1", Amount: 3})
+		if err != nil {
+			t.Fatalf("%s Marshal: %v", codec.ContentType(), err)
+		}
+		var lenient codecSampleNarrow
+		if err := codec.Unmarshal(data, &lenient, false); err != nil {
+			t.Errorf("%s 非严格模式应忽略未知字段: %v", codec.ContentType(), err)
+		}
+		if lenient.OrderID != "o-1" {
+			t.Errorf("%s order_id = %q", codec.ContentType(), lenient.OrderID)
+		}
+		var strict codecSampleNarrow
+		if err := codec.Unmarshal(data, &strict, true); err == nil {
+			t.Errorf("%s 严格模式应拒绝未知字段", codec.ContentType())
+		}
+	}
+}
+
+func TestCodecForContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{"", ContentTypeJSON, false},
+		{"application/json", ContentTypeJSON, false},
+		{"application/json; charset=utf-8", ContentTypeJSON, false},
+		{"application/msgpack", ContentTypeMsgpack, false},
+		{"application/x-msgpack", ContentTypeMsgpack, false},
+		{"application/x-protobuf; proto=ordercenter.v1.OrderMessage", "application/x-protobuf; proto=ordercenter.v1.OrderMessage", false},
+		{"application/protobuf; proto=ordercenter.v1.OrderEvent", "application/x-protobuf; proto=ordercenter.v1.OrderEvent", false},
+		// 必须指定已注册的消息类型
+		{"application/x-protobuf", "", true},
+		{"application/x-protobuf; proto=ordercenter.v1.Missing", "", true},
+		{"text/plain", "", true},
+		{";;", "", true},
+	}
+	for _, tt := range tests {
+		codec, err := CodecForContentType(tt.contentType)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsupportedContentType) {
+				t.Errorf("CodecForContentType(%q) err = %v, want ErrUnsupportedContentType", tt.contentType, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CodecForContentType(%q): %v", tt.contentType, err)
+			continue
+		}
+		if codec.ContentType() != tt.want {
+			t.Errorf("CodecForContentType(%q) = %s, want %s", tt.contentType, codec.ContentType(), tt.want)
+		}
+	}
+}
+
+func TestCodecForExchange(t *testing.T) {
+	tests := []struct {
+		exchange config.ExchangeConfig
+		want     string
+		wantErr  bool
+	}{
+		{config.ExchangeConfig{}, ContentTypeJSON, false},
+		{config.ExchangeConfig{Encoding: config.EncodingJSON}, ContentTypeJSON, false},
+		{config.ExchangeConfig{Encoding: config.EncodingMsgpack}, ContentTypeMsgpack, false},
+		{config.ExchangeConfig{Encoding: config.EncodingProtobuf, ProtoMessage: "ordercenter.v1.OrderEvent"},
+			"application/x-protobuf; proto=ordercenter.v1.OrderEvent", false},
+		{config.ExchangeConfig{Encoding: config.EncodingProtobuf}, "", true},
+		{config.ExchangeConfig{Encoding: config.EncodingProtobuf, ProtoMessage: "ordercenter.v1.Missing"}, "", true},
+		{config.ExchangeConfig{Encoding: "avro"}, "", true},
+	}
+	for _, tt := range tests {
+		codec, err := CodecForExchange(tt.exchange)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CodecForExchange(%+v) err = %v, wantErr %v", tt.exchange, err, tt.wantErr)
+			continue
+		}
+		if err == nil && codec.ContentType() != tt.want {
+			t.Errorf("CodecForExchange(%+v) = %s, want %s", tt.exchange, codec.ContentType(), tt.want)
+		}
+	}
+}
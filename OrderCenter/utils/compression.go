@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/streadway/amqp"
+)
+
+// 消息体的 content_encoding
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// 解压后的最大长度，防止异常消息占满内存
+const maxDecompressedSize = 64 << 20
+
+var errDecompressedTooLarge = fmt.Errorf("解压后超过 %d 字节", maxDecompressedSize)
+
+// zstd 的 EncodeAll / DecodeAll 可以并发调用，整个进程共用一份
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+)
+
+// SetCompression 设置发布时的压缩方式：消息体达到 threshold 字节时按 encoding 压缩并设置 content_encoding。
+// encoding 为 none 或 threshold <= 0 时不压缩。消费端按消息的 content_encoding 解压，与这里的设置无关。
+func (rmq *RabbitMQ) SetCompression(encoding string, threshold int) error {
+	switch encoding {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("不支持的压缩方式: %q", encoding)
+	}
+	rmq.compression = encoding
+	rmq.compressThreshold = threshold
+	return nil
+}
+
+// compressPublishing 超过阈值时压缩消息体，已经设置了 content_encoding 的消息（如转发的死信）保持原样
+func (rmq *RabbitMQ) compressPublishing(msg *amqp.Publishing) error {
+	if rmq.compression == "" || rmq.compression == CompressionNone || rmq.compressThreshold <= 0 ||
+		msg.ContentEncoding != "" || len(msg.Body) < rmq.compressThreshold {
+		return nil
+	}
+	body, err := compress(rmq.compression, msg.Body)
+	if err != nil {
+		return fmt.Errorf("压缩消息失败: %w", err)
+	}
+	msg.Body = body
+	msg.ContentEncoding = rmq.compression
+	return nil
+}
+
+func compress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	}
+	return nil, fmt.Errorf("不支持的压缩方式: %q", encoding)
+}
+
+// decompressDelivery 按 content_encoding 就地解压消息体，之后 content_encoding 置空。
+// 无法解压的消息重试也不会成功，返回永久错误。
+func decompressDelivery(d *amqp.Delivery) error {
+	if d.ContentEncoding == "" || d.ContentEncoding == "identity" {
+		return nil
+	}
+	body, err := decompress(d.ContentEncoding, d.Body)
+	if err != nil {
+		return Permanent(fmt.Errorf("按 %s 解压消息失败: %w", d.ContentEncoding, err))
+	}
+	d.Body = body
+	d.ContentEncoding = ""
+	return nil
+}
+
+func decompress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > maxDecompressedSize {
+			return nil, errDecompressedTooLarge
+		}
+		return out, nil
+	case CompressionZstd:
+		out, err := zstdDecoder.DecodeAll(body, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			return nil, errDecompressedTooLarge
+		}
+		return out, err
+	}
+	return nil, fmt.Errorf("不支持的 content_encoding: %q", encoding)
+}
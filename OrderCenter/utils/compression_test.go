@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	bodies := map[string][]byte{
+		"empty":  {},
+		"small":  []byte(`{"order_id":"o-1"}`),
+		"repeat": bytes.Repeat([]byte("order"), 10000),
+	}
+	for _, encoding := range []string{CompressionGzip, CompressionZstd} {
+		for name, body := range bodies {
+			t.Run(encoding+"/"+name, func(t *testing.T) {
+				compressed, err := compress(encoding, body)
+				if err != nil {
+					t.Fatalf("compress: %v", err)
+				}
+				got, err := decompress(encoding, compressed)
+				if err != nil {
+					t.Fatalf("decompress: %v", err)
+				}
+				if !bytes.Equal(got, body) {
+					t.Fatalf("解压结果与原文不一致: %d 字节, want %d", len(got), len(body))
+				}
+			})
+		}
+	}
+}
+
+func TestCompressUnsupported(t *testing.T) {
+	if _, err := compress("br", []byte("x")); err == nil {
+		t.Error("compress 应拒绝不支持的压缩方式")
+	}
+	if _, err := decompress("br", []byte("x")); err == nil {
+		t.Error("decompress 应拒绝不支持的 content_encoding")
+	}
+}
+
+func TestDecompressSizeLimit(t *testing.T) {
+	tests := []struct {
+		size    int
+		wantErr bool
+	}{
+		{maxDecompressedSize, false},
+		{maxDecompressedSize + 1, true},
+	}
+	for _, encoding := range []string{CompressionGzip, CompressionZstd} {
+		for _, tt := range tests {
+			compressed, err := compress(encoding, make([]byte, tt.size))
+			if err != nil {
+				t.Fatalf("%s compress: %v", encoding, err)
+			}
+			out, err := decompress(encoding, compressed)
+			if tt.wantErr {
+				if !errors.Is(err, errDecompressedTooLarge) {
+					t.Errorf("%s %d 字节: err = %v, want errDecompressedTooLarge", encoding, tt.size, err)
+				}
+				continue
+			}
+			if err != nil || len(out) != tt.size {
+				t.Errorf("%s %d 字节: len = %d, err = %v", encoding, tt.size, len(out), err)
+			}
+		}
+	}
+}
+
+func TestDecompressDelivery(t *testing.T) {
+	body := []byte(`{"order_id":"o-1"}`)
+	zstdBody, err := compress(CompressionZstd, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		delivery      amqp.Delivery
+		wantBody      []byte
+		wantEncoding  string
+		wantPermanent bool
+	}{
+		{"未压缩", amqp.Delivery{Body: body}, body, "", false},
+		{"identity", amqp.Delivery{ContentEncoding: "identity", Body: body}, body, "identity", false},
+		{"zstd", amqp.Delivery{ContentEncoding: CompressionZstd, Body: zstdBody}, body, "", false},
+		{"损坏的 gzip", amqp.Delivery{ContentEncoding: CompressionGzip, Body: []byte("not gzip")}, nil, "", true},
+		{"未知编码", amqp.Delivery{ContentEncoding: "br", Body: body}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.delivery
+			err := decompressDelivery(&d)
+			if tt.wantPermanent {
+				if !IsPermanent(err) {
+					t.Fatalf("err = %v, want 永久错误", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decompressDelivery: %v", err)
+			}
+			if !bytes.Equal(d.Body, tt.wantBody) || d.ContentEncoding != tt.wantEncoding {
+				t.Fatalf("body = %q, content_encoding = %q", d.Body, d.ContentEncoding)
+			}
+		})
+	}
+}
+
+func TestCompressPublishing(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 100)
+	tests := []struct {
+		name         string
+		encoding     string
+		threshold    int
+		msg          amqp.Publishing
+		wantEncoding string
+	}{
+		{"未开启", CompressionNone, 10, amqp.Publishing{Body: body}, ""},
+		{"阈值为 0", CompressionGzip, 0, amqp.Publishing{Body: body}, ""},
+		{"低于阈值", CompressionGzip, 101, amqp.Publishing{Body: body}, ""},
+		{"达到阈值", CompressionGzip, 100, amqp.Publishing{Body: body}, CompressionGzip},
+		{"已设置 content_encoding", CompressionZstd, 10, amqp.Publishing{ContentEncoding: CompressionGzip, Body: body}, CompressionGzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rmq := &RabbitMQ{}
+			if err := rmq.SetCompression(tt.encoding, tt.threshold); err != nil {
+				t.Fatal(err)
+			}
+			msg := tt.msg
+			if err := rmq.compressPublishing(&msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.ContentEncoding != tt.wantEncoding {
+				t.Fatalf("content_encoding = %q, want %q", msg.ContentEncoding, tt.wantEncoding)
+			}
+			if tt.wantEncoding == "" && !bytes.Equal(msg.Body, body) {
+				t.Fatal("未压缩的消息体被修改")
+			}
+		})
+	}
+	if err := (&RabbitMQ{}).SetCompression("br", 1); err == nil {
+		t.Error("SetCompression 应拒绝不支持的压缩方式")
+	}
+}
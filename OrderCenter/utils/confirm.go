@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+var errPublishNacked = errors.New("broker 拒绝了消息")
+
+// confirmPublisher 开启了 publisher confirms 的发布通道。
+// 每次只有一条消息在途，确认按顺序对应；通道出错或等待超时后丢弃，下次发布时重新打开。
+type confirmPublisher struct {
+	mu       sync.Mutex
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func (p *confirmPublisher) publish(ctx context.Context, rmq *RabbitMQ, exchange, routingKey string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil {
+		if err := p.open(rmq); err != nil {
+			return err
+		}
+	}
+	if err := p.ch.Publish(exchange, routingKey, false, false, msg); err != nil {
+		p.reset()
+		return err
+	}
+
+	select {
+	case c, ok := <-p.confirms:
+		if !ok {
+			// 通道已关闭，例如交换机不存在
+			p.reset()
+			return errors.New("等待确认时通道关闭")
+		}
+		if !c.Ack {
+			return errPublishNacked
+		}
+		return nil
+	case <-ctx.Done():
+		// 迟到的确认会和下一条消息错位，直接换一个通道
+		p.reset()
+		return fmt.Errorf("等待 broker 确认超时: %w", ctx.Err())
+	}
+}
+
+func (p *confirmPublisher) open(rmq *RabbitMQ) error {
+	ch, err := rmq.openChannel()
+	if err != nil {
+		return err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("开启发布确认失败: %w", err)
+	}
+	p.ch = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+func (p *confirmPublisher) reset() {
+	if p.ch != nil {
+		p.ch.Close()
+	}
+	p.ch, p.confirms = nil, nil
+}
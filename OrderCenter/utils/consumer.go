@@ -0,0 +1,358 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+	"trade-solution/ordercenter/config"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/metrics"
+	"trade-solution/ordercenter/tracing"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MessageHandler 处理单条消息，返回 nil 时确认，返回 error 时按重试策略处理
+type MessageHandler func(ctx context.Context, d amqp.Delivery) error
+
+// Middleware 包装 MessageHandler，用于日志、指标、异常恢复等横切逻辑
+type Middleware func(next MessageHandler) MessageHandler
+
+// RetryPolicy 处理失败后的重试策略。
+// 重试通过 Nack 重新入队实现，次数取自 quorum 队列的 x-delivery-count，
+// 经典队列没有这个消息头，无法计数，所以只能在拓扑中声明为 quorum 的队列上配置重试；
+// 超过次数或永久错误时转发到队列配置的死信交换机，并在 x-death-reason 消息头中写明原因；
+// 未配置死信交换机或转发失败时 Nack 不重新入队。
+type RetryPolicy struct {
+	MaxAttempts int           // 包含首次投递，<= 1 表示不重试
+	Backoff     time.Duration // 重新入队前等待的时间
+}
+
+type ConsumerOptions struct {
+	Queue    string
+	Workers  int
+	Prefetch int
+	Retry    RetryPolicy
+	// 每条消息的处理超时，0 表示不限制
+	Timeout    time.Duration
+	Middleware []Middleware
+	// 为空时使用 RabbitMQ 的日志并带上 queue 字段
+	Logger *slog.Logger
+	// 批量消费，未启用时逐条调用 MessageHandler；
+	// 批量处理失败时仍会用 MessageHandler 逐条处理，所以两者都要提供；Middleware 只作用于 MessageHandler
+	Batch BatchOptions
+}
+
+// Consumer 通用的并发消费者：一个订阅、多个 worker 共享 deliveries
+type Consumer struct {
+	rmq     *RabbitMQ
+	opts    ConsumerOptions
+	handler MessageHandler
+	logger  *slog.Logger
+	tag     string
+	running atomic.Int32 // 存活的 worker 数
+}
+
+func NewConsumer(rmq *RabbitMQ, handler MessageHandler, opts ConsumerOptions) *Consumer {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = opts.Workers
+		// 批量模式下每个 worker 要能同时持有一整批
+		if opts.Batch.enabled() {
+			opts.Prefetch = opts.Workers * opts.Batch.Size
+		}
+	}
+	// 第一个中间件在最外层
+	for i := len(opts.Middleware) - 1; i >= 0; i-- {
+		handler = opts.Middleware[i](handler)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = rmq.logger.With("queue", opts.Queue)
+	}
+	return &Consumer{rmq: rmq, opts: opts, handler: handler, logger: logger}
+}
+
+// Queue 消费的队列名
+func (c *Consumer) Queue() string {
+	return c.opts.Queue
+}
+
+// Running 存活的 worker 数
+func (c *Consumer) Running() int {
+	return int(c.running.Load())
+}
+
+// Workers 配置的 worker 数
+func (c *Consumer) Workers() int {
+	return c.opts.Workers
+}
+
+// Subscribed 是否已在 broker 上订阅，断线重连期间为 false
+func (c *Consumer) Subscribed() bool {
+	return c.tag != "" && c.rmq.Subscribed(c.tag)
+}
+
+// Start 订阅队列并启动 worker，立即返回。
+// ctx 取消后停止订阅，worker 处理完当前消息后退出，wg 用于等待全部 worker 退出。
+func (c *Consumer) Start(ctx context.Context, wg *sync.WaitGroup) error {
+	if err := c.checkRetry(); err != nil {
+		return err
+	}
+	if c.opts.Prefetch > config.MaxPrefetch {
+		return fmt.Errorf("队列 %s 的 prefetch %d 超过上限 %d", c.opts.Queue, c.opts.Prefetch, config.MaxPrefetch)
+	}
+	consumerTag := fmt.Sprintf("%s-%d", c.opts.Queue, time.Now().UnixNano())
+	c.tag = consumerTag
+	deliveries, err := c.rmq.Consume(ctx, c.opts.Queue, consumerTag, c.opts.Prefetch)
+	if err != nil {
+		return fmt.Errorf("从队列 %s 消费失败: %w", c.opts.Queue, err)
+	}
+	c.logger.Info("开始消费队列", "workers", c.opts.Workers, "prefetch", c.opts.Prefetch, "batch_size", c.opts.Batch.Size)
+
+	for i := 0; i < c.opts.Workers; i++ {
+		wg.Add(1)
+		c.running.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			defer c.running.Add(-1)
+			c.supervise(ctx, workerID, deliveries)
+		}(i)
+	}
+	return nil
+}
+
+// checkRetry 配置了重试的队列必须是拓扑中的 quorum 队列，否则重试次数一直停在 2，消息会无限重试
+func (c *Consumer) checkRetry() error {
+	if c.opts.Retry.MaxAttempts <= 1 {
+		return nil
+	}
+	if c.rmq.topology != nil {
+		if q, ok := c.rmq.topology.Queue(c.opts.Queue); ok && q.Quorum {
+			return nil
+		}
+	}
+	return fmt.Errorf("队列 %s 不是拓扑中声明的 quorum 队列，不能配置重试（max_attempts=%d）", c.opts.Queue, c.opts.Retry.MaxAttempts)
+}
+
+// supervise 运行 worker，worker 因 panic 退出时重新启动，直到 deliveries 关闭
+func (c *Consumer) supervise(ctx context.Context, workerID int, deliveries <-chan amqp.Delivery) {
+	work := c.work
+	if c.opts.Batch.enabled() {
+		work = c.workBatches
+	}
+	for !work(ctx, workerID, deliveries) {
+		c.logger.Warn("worker 异常退出，重新启动", "worker", workerID)
+	}
+}
+
+// work 处理消息直到 deliveries 关闭时返回 true；发生 panic 时返回 false
+func (c *Consumer) work(ctx context.Context, workerID int, deliveries <-chan amqp.Delivery) (finished bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("worker panic", "worker", workerID, "panic", r, "stack", string(debug.Stack()))
+			finished = false
+		}
+	}()
+
+	for d := range deliveries {
+		// 停机后已预取但未处理的消息退回队列
+		if ctx.Err() != nil {
+			d.Nack(false, true)
+			continue
+		}
+		c.process(ctx, workerID, d)
+	}
+	return true
+}
+
+// process 处理并确认单条消息。
+// 当前消息即使在停机过程中也要处理完，所以不继承 ctx 的取消，只受单条超时限制。
+func (c *Consumer) process(ctx context.Context, workerID int, d amqp.Delivery) {
+	msgCtx, cancel := c.messageContext(ctx)
+	defer cancel()
+	msgCtx, span := c.startSpan(msgCtx, d)
+	defer span.End()
+
+	start := time.Now()
+	// 压缩过的消息先按 content_encoding 解压，处理函数只看到原始消息体
+	err := decompressDelivery(&d)
+	if err == nil {
+		err = c.handle(msgCtx, d)
+	}
+	metrics.ConsumerLatency.WithLabelValues(c.opts.Queue).Observe(time.Since(start).Seconds())
+
+	if c.settle(msgCtx, span, workerID, d, err) {
+		c.requeue(ctx, d)
+	}
+}
+
+// messageContext 处理消息使用的 ctx：不继承停机取消，只受 Timeout 限制
+func (c *Consumer) messageContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	msgCtx := context.WithoutCancel(ctx)
+	if c.opts.Timeout > 0 {
+		return context.WithTimeout(msgCtx, c.opts.Timeout)
+	}
+	return msgCtx, func() {}
+}
+
+// startSpan 为单条消息设置请求 ID 并接上发布方的 trace
+func (c *Consumer) startSpan(ctx context.Context, d amqp.Delivery) (context.Context, trace.Span) {
+	// 沿用上游的 correlation_id 作为请求 ID，继续发布的消息会带上同一个 ID
+	requestID := d.CorrelationId
+	if requestID == "" {
+		requestID = logging.NewRequestID()
+	}
+	ctx = logging.WithRequestID(ctx, requestID)
+
+	// 处理过程中的 DB 和发布操作都挂在这个 span 下
+	ctx = tracing.ExtractAMQP(ctx, d.Headers)
+	return tracing.Tracer().Start(ctx, c.opts.Queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.source.name", c.opts.Queue),
+			attribute.String("messaging.rabbitmq.destination.routing_key", d.RoutingKey),
+			attribute.Int("messaging.rabbitmq.delivery_attempt", deliveryAttempt(d)),
+		))
+}
+
+// settle 按处理结果确认消息：成功或重复时 Ack，永久错误或重试耗尽时转入死信。
+// 需要重新入队时返回 true，由调用方退避后 Nack，批量处理时只退避一次。
+func (c *Consumer) settle(ctx context.Context, span trace.Span, workerID int, d amqp.Delivery, err error) (requeue bool) {
+	if err != nil && !errors.Is(err, ErrDuplicate) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if err == nil {
+		metrics.ConsumerMessages.WithLabelValues(c.opts.Queue, metrics.ResultProcessed).Inc()
+		d.Ack(false) // 成功确认
+		return false
+	}
+	if errors.Is(err, ErrDuplicate) {
+		metrics.ConsumerMessages.WithLabelValues(c.opts.Queue, metrics.ResultDuplicate).Inc()
+		c.logger.WarnContext(ctx, "重复消息，跳过", logging.Err(err))
+		d.Ack(false)
+		return false
+	}
+
+	metrics.ConsumerMessages.WithLabelValues(c.opts.Queue, metrics.ResultFailed).Inc()
+	attempt := deliveryAttempt(d)
+	if IsPermanent(err) || attempt >= c.opts.Retry.MaxAttempts {
+		c.logger.ErrorContext(ctx, "处理消息失败，不再重试", "worker", workerID, "attempt", attempt, logging.Err(err))
+		metrics.ConsumerMessages.WithLabelValues(c.opts.Queue, metrics.ResultDeadLettered).Inc()
+		c.deadLetter(ctx, d, err)
+		return false
+	}
+
+	c.logger.WarnContext(ctx, "处理消息失败，重新入队", "worker", workerID, "attempt", attempt, "retry_in", c.opts.Retry.Backoff, logging.Err(err))
+	return true
+}
+
+// requeue 退避后把消息退回队列，停机时不再等待
+func (c *Consumer) requeue(ctx context.Context, ds ...amqp.Delivery) {
+	if c.opts.Retry.Backoff > 0 {
+		timer := time.NewTimer(c.opts.Retry.Backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	for _, d := range ds {
+		d.Nack(false, true)
+	}
+}
+
+// deadLetter 带上失败原因转发到死信交换机后确认原消息。
+// 转发失败时退回 Nack，由 broker 按队列的 x-dead-letter-exchange 投递，只是没有原因。
+func (c *Consumer) deadLetter(ctx context.Context, d amqp.Delivery, cause error) {
+	// 单条超时后仍要转发，不受 ctx 超时影响
+	err := c.rmq.DeadLetter(context.WithoutCancel(ctx), c.opts.Queue, d, cause.Error())
+	if err == nil {
+		d.Ack(false)
+		return
+	}
+	if !errors.Is(err, errNoDeadLetter) {
+		c.logger.WarnContext(ctx, "转发死信失败，改为 Nack", logging.Err(err))
+	}
+	d.Nack(false, false)
+}
+
+// handle 调用处理函数，panic 转为永久错误，消息直接进入死信队列而不是反复重试
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.ErrorContext(ctx, "处理消息 panic", "panic", r, "stack", string(debug.Stack()))
+			err = Permanent(fmt.Errorf("处理消息 panic: %v", r))
+		}
+	}()
+	return c.handler(ctx, d)
+}
+
+// deliveryAttempt 当前是第几次投递，quorum 队列在重新投递时带 x-delivery-count。
+// 经典队列只能区分首次投递和重新投递，所以 checkRetry 不允许在经典队列上重试。
+func deliveryAttempt(d amqp.Delivery) int {
+	switch n := d.Headers["x-delivery-count"].(type) {
+	case int64:
+		return int(n) + 1
+	case int32:
+		return int(n) + 1
+	case int:
+		return n + 1
+	}
+	if d.Redelivered {
+		return 2
+	}
+	return 1
+}
+
+// ErrDuplicate 消息已处理过，直接确认，不算失败
+var ErrDuplicate = errors.New("重复消息")
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记为不可重试的错误，例如消息格式错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// LoggingMiddleware 记录每条消息的处理耗时和结果
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, d amqp.Delivery) error {
+			start := time.Now()
+			err := next(ctx, d)
+			if err != nil {
+				logger.WarnContext(ctx, "消息处理失败", "elapsed", time.Since(start), logging.Err(err))
+			} else {
+				logger.DebugContext(ctx, "消息处理完成", "elapsed", time.Since(start))
+			}
+			return err
+		}
+	}
+}
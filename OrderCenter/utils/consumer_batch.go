@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+	"trade-solution/ordercenter/metrics"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BatchItem 批量处理中的一条消息，Ctx 带有这条消息的请求 ID 和 trace
+type BatchItem struct {
+	Ctx      context.Context
+	Delivery amqp.Delivery
+}
+
+// BatchHandler 一次处理多条消息，返回与 items 一一对应的错误，每条消息按自己的结果确认
+type BatchHandler func(ctx context.Context, items []BatchItem) []error
+
+// BatchOptions 批量消费：每个 worker 凑满 Size 条或等待 Wait 后交给 Handler 一起处理。
+// Size <= 1 或 Handler 为空时逐条处理。
+type BatchOptions struct {
+	Size    int
+	Wait    time.Duration
+	Handler BatchHandler
+}
+
+func (o BatchOptions) enabled() bool {
+	return o.Size > 1 && o.Handler != nil
+}
+
+// workBatches 批量模式下的 worker 循环，返回值含义同 work
+func (c *Consumer) workBatches(ctx context.Context, workerID int, deliveries <-chan amqp.Delivery) (finished bool) {
+	var batch []amqp.Delivery
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("worker panic", "worker", workerID, "panic", r, "stack", string(debug.Stack()))
+			// 已取出但未确认的消息退回队列
+			for _, d := range batch {
+				d.Nack(false, true)
+			}
+			finished = false
+		}
+	}()
+
+	for {
+		// 等待这一批的第一条
+		d, ok := <-deliveries
+		if !ok {
+			return true
+		}
+		if ctx.Err() != nil {
+			d.Nack(false, true)
+			continue
+		}
+		batch = append(make([]amqp.Delivery, 0, c.opts.Batch.Size), d)
+
+		// 凑满 Size 条或等到 Wait 为止；停机后不再凑批，已取出的照常处理
+		deadline := time.After(c.opts.Batch.Wait)
+		closed := false
+	fill:
+		for len(batch) < c.opts.Batch.Size && ctx.Err() == nil {
+			select {
+			case d, ok := <-deliveries:
+				if !ok {
+					closed = true
+					break fill
+				}
+				batch = append(batch, d)
+			case <-deadline:
+				break fill
+			case <-ctx.Done():
+				break fill
+			}
+		}
+
+		c.processBatch(ctx, workerID, batch)
+		batch = nil
+		if closed {
+			return true
+		}
+	}
+}
+
+// processBatch 处理并逐条确认一批消息，单条失败不影响同批其他消息
+func (c *Consumer) processBatch(ctx context.Context, workerID int, batch []amqp.Delivery) {
+	batchCtx, cancel := c.messageContext(ctx)
+	defer cancel()
+
+	errs := make([]error, len(batch))
+	spans := make([]trace.Span, len(batch))
+	ctxs := make([]context.Context, len(batch))
+	items := make([]BatchItem, 0, len(batch))
+	positions := make([]int, 0, len(batch))
+	for i := range batch {
+		ctxs[i], spans[i] = c.startSpan(batchCtx, batch[i])
+		spans[i].SetAttributes(attribute.Int("messaging.batch.message_count", len(batch)))
+		if err := decompressDelivery(&batch[i]); err != nil {
+			errs[i] = err
+			continue
+		}
+		items = append(items, BatchItem{Ctx: ctxs[i], Delivery: batch[i]})
+		positions = append(positions, i)
+	}
+
+	start := time.Now()
+	if len(items) > 0 {
+		for j, err := range c.handleBatch(batchCtx, items) {
+			errs[positions[j]] = err
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	metrics.ConsumerBatchSize.WithLabelValues(c.opts.Queue).Observe(float64(len(batch)))
+
+	var retry []amqp.Delivery
+	for i := range batch {
+		metrics.ConsumerLatency.WithLabelValues(c.opts.Queue).Observe(elapsed)
+		if c.settle(ctxs[i], spans[i], workerID, batch[i], errs[i]) {
+			retry = append(retry, batch[i])
+		}
+		spans[i].End()
+	}
+	if len(retry) > 0 {
+		c.requeue(ctx, retry...)
+	}
+}
+
+// handleBatch 调用批量处理函数。panic 或返回的结果条数不对时改为逐条处理，
+// 避免一条异常消息拖累同批其他消息。
+func (c *Consumer) handleBatch(ctx context.Context, items []BatchItem) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.ErrorContext(ctx, "批量处理 panic，改为逐条处理", "panic", r, "stack", string(debug.Stack()))
+			errs = c.handleEach(items)
+		}
+	}()
+	errs = c.opts.Batch.Handler(ctx, items)
+	if len(errs) != len(items) {
+		c.logger.ErrorContext(ctx, "批量处理结果条数不符，改为逐条处理", "items", len(items), "results", len(errs))
+		return c.handleEach(items)
+	}
+	return errs
+}
+
+func (c *Consumer) handleEach(items []BatchItem) []error {
+	errs := make([]error, len(items))
+	for i, item := range items {
+		errs[i] = c.handle(item.Ctx, item.Delivery)
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"trade-solution/ordercenter/config"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeAcknowledger 记录消息的确认结果
+type fakeAcknowledger struct {
+	mu       sync.Mutex
+	acked    []uint64
+	requeued []uint64
+	rejected []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	} else {
+		a.rejected = append(a.rejected, tag)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestDeliveryAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     int
+	}{
+		{"首次投递", amqp.Delivery{}, 1},
+		{"经典队列重新投递", amqp.Delivery{Redelivered: true}, 2},
+		{"x-delivery-count int64", amqp.Delivery{Redelivered: true, Headers: amqp.Table{"x-delivery-count": int64(3)}}, 4},
+		{"x-delivery-count int32", amqp.Delivery{Headers: amqp.Table{"x-delivery-count": int32(1)}}, 2},
+		{"x-delivery-count int", amqp.Delivery{Headers: amqp.Table{"x-delivery-count": 0}}, 1},
+		{"x-delivery-count 类型未知", amqp.Delivery{Redelivered: true, Headers: amqp.Table{"x-delivery-count": "5"}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveryAttempt(tt.delivery); got != tt.want {
+				t.Fatalf("deliveryAttempt = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRetry(t *testing.T) {
+	topology := &config.Topology{
+		Queues: []config.QueueConfig{
+			{Name: "quorum_queue", Quorum: true},
+			{Name: "classic_queue"},
+		},
+	}
+	tests := []struct {
+		name     string
+		topology *config.Topology
+		queue    string
+		attempts int
+		wantErr  bool
+	}{
+		{"不重试", nil, "classic_queue", 1, false},
+		{"quorum 队列", topology, "quorum_queue", 3, false},
+		{"经典队列", topology, "classic_queue", 3, true},
+		{"拓扑中没有的队列", topology, "unknown_queue", 3, true},
+		{"没有拓扑", nil, "quorum_queue", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Consumer{
+				rmq:  &RabbitMQ{topology: tt.topology},
+				opts: ConsumerOptions{Queue: tt.queue, Retry: RetryPolicy{MaxAttempts: tt.attempts}},
+			}
+			if err := c.checkRetry(); (err != nil) != tt.wantErr {
+				t.Fatalf("checkRetry err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  time.Duration
+		canceled bool
+		maxWait  time.Duration
+	}{
+		{"无退避", 0, false, time.Second},
+		{"等待退避", 20 * time.Millisecond, false, time.Second},
+		// 停机时不等退避，立即退回队列
+		{"停机", time.Hour, true, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			ack := &fakeAcknowledger{}
+			c := &Consumer{opts: ConsumerOptions{Retry: RetryPolicy{Backoff: tt.backoff}}}
+
+			start := time.Now()
+			c.requeue(ctx,
+				amqp.Delivery{Acknowledger: ack, DeliveryTag: 1},
+				amqp.Delivery{Acknowledger: ack, DeliveryTag: 2})
+			elapsed := time.Since(start)
+
+			if elapsed > tt.maxWait {
+				t.Fatalf("requeue 耗时 %v", elapsed)
+			}
+			if !tt.canceled && elapsed < tt.backoff {
+				t.Fatalf("requeue 耗时 %v，未等待退避 %v", elapsed, tt.backoff)
+			}
+			if len(ack.requeued) != 2 || len(ack.acked) != 0 || len(ack.rejected) != 0 {
+				t.Fatalf("requeued=%v acked=%v rejected=%v", ack.requeued, ack.acked, ack.rejected)
+			}
+		})
+	}
+}
@@ -4,18 +4,60 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
+	"trade-solution/ordercenter/config"
+	"trade-solution/ordercenter/logging"
+	"trade-solution/ordercenter/metrics"
+	"trade-solution/ordercenter/tracing"
 
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var rabbitMQInstance *RabbitMQ
 
+var errNotConnected = errors.New("RabbitMQ 未连接")
+
+var errNoDeadLetter = errors.New("队列未配置死信交换机")
+
+// RabbitMQ 连接管理器。
+// 整个进程共用一个实例：断线后在内部重连并替换连接，调用方持有的指针始终有效。
+// 发布使用独立的发布通道，每个消费者各自占用一个通道（各自的 Qos），
+// 通道或连接关闭后消费者会自动重新订阅。
 type RabbitMQ struct {
-	Conn    *amqp.Connection
-	Channel *amqp.Channel
-	Queues  map[string]amqp.Queue // 新增: 存储已声明队列
+	url      string
+	topology *config.Topology
+	logger   *slog.Logger
+	// 交换机 -> 发布编码，未配置的交换机使用 JSON
+	codecs map[string]Codec
+	// 发布时的压缩方式和阈值（字节），见 SetCompression
+	compression       string
+	compressThreshold int
+
+	mu    sync.RWMutex
+	conn  *amqp.Connection
+	pubCh *amqp.Channel
+	// PublishConfirmed 使用的确认通道
+	confirm confirmPublisher
+
+	queuesMu sync.RWMutex
+	Queues   map[string]amqp.Queue // 新增: 存储已声明队列
+
+	connected  atomic.Bool
+	pubOpen    atomic.Bool
+	publishing sync.WaitGroup // 进行中的发布，关闭前等待
+
+	// consumerTag -> 是否已在 broker 上订阅
+	subscriptions sync.Map
+
+	// 重连退避：从 ReconnectMin 开始指数增长，最长 ReconnectMax，带随机抖动
+	ReconnectMin time.Duration
+	ReconnectMax time.Duration
 }
 
 func GetRabbitMQInstance() (*RabbitMQ, error) {
@@ -25,191 +67,581 @@ func GetRabbitMQInstance() (*RabbitMQ, error) {
 	return rabbitMQInstance, nil
 }
 
-func InitRabbitMQ(url string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(url)
+// InitRabbitMQ 建立连接并声明拓扑，之后需要调用 Run 监听断线重连
+func InitRabbitMQ(url string, topology *config.Topology, logger *slog.Logger) (*RabbitMQ, error) {
+	rmq, err := NewRabbitMQ(url, topology, logger)
 	if err != nil {
-		return nil, fmt.Errorf("连接 RabbitMQ 失败: %w", err)
+		return nil, err
 	}
-	ch, err := conn.Channel()
+	if err := rmq.connect(); err != nil {
+		return nil, err
+	}
+	rabbitMQInstance = rmq
+	return rmq, nil
+}
+
+// NewRabbitMQ 按拓扑准备各交换机的发布编码，不建立连接
+func NewRabbitMQ(url string, topology *config.Topology, logger *slog.Logger) (*RabbitMQ, error) {
+	rmq := &RabbitMQ{
+		url:          url,
+		topology:     topology,
+		logger:       logger.With("component", "rabbitmq"),
+		Queues:       make(map[string]amqp.Queue),
+		ReconnectMin: 500 * time.Millisecond,
+		ReconnectMax: 30 * time.Second,
+		codecs:       make(map[string]Codec),
+	}
+	if topology != nil {
+		for _, ex := range topology.Exchanges {
+			codec, err := CodecForExchange(ex)
+			if err != nil {
+				return nil, fmt.Errorf("交换机 %s: %w", ex.Name, err)
+			}
+			rmq.codecs[ex.Name] = codec
+		}
+	}
+	return rmq, nil
+}
+
+// connect 建立连接并在新连接上声明拓扑、打开发布通道，全部成功后才替换当前连接。
+// 失败时关闭新连接，当前状态保持不变。
+func (rmq *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(rmq.url)
+	if err != nil {
+		return fmt.Errorf("连接 RabbitMQ 失败: %w", err)
+	}
+	if rmq.topology != nil {
+		if err := rmq.declareTopologyOn(conn); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	pubCh, err := conn.Channel()
 	if err != nil {
 		conn.Close()
+		return fmt.Errorf("打开通道失败: %w", err)
+	}
+
+	rmq.mu.Lock()
+	rmq.conn = conn
+	rmq.pubCh = pubCh
+	rmq.pubOpen.Store(true)
+	rmq.connected.Store(true)
+	rmq.mu.Unlock()
+	return nil
+}
+
+// declareTopologyOn 在尚未替换的新连接上用临时通道声明拓扑
+func (rmq *RabbitMQ) declareTopologyOn(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("打开通道失败: %w", err)
+	}
+	defer ch.Close()
+	return rmq.declareTopology(ch, rmq.topology)
+}
+
+// Connected 连接和发布通道是否可用
+func (rmq *RabbitMQ) Connected() bool {
+	return rmq.connected.Load()
+}
+
+// Run 监听连接和发布通道的关闭事件并自动恢复，阻塞直到 ctx 取消后关闭连接
+func (rmq *RabbitMQ) Run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			rmq.Close()
+			rmq.logger.Info("RabbitMQ 监控退出")
+			return
+		}
+
+		rmq.mu.RLock()
+		conn, pubCh := rmq.conn, rmq.pubCh
+		rmq.mu.RUnlock()
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		pubClosed := pubCh.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-ctx.Done():
+			rmq.Close()
+			rmq.logger.Info("RabbitMQ 监控退出")
+			return
+		case err := <-connClosed:
+			rmq.logger.Warn("RabbitMQ 连接关闭，准备重连", logging.Err(err))
+			rmq.connected.Store(false)
+			rmq.pubOpen.Store(false)
+			rmq.reconnect(ctx)
+		case err := <-pubClosed:
+			rmq.logger.Warn("RabbitMQ 发布通道关闭，重新打开", logging.Err(err))
+			rmq.connected.Store(false)
+			rmq.pubOpen.Store(false)
+			if err := rmq.reopenPublisher(conn); err != nil {
+				rmq.logger.Error("重新打开发布通道失败，准备重连", logging.Err(err))
+				conn.Close()
+				rmq.reconnect(ctx)
+			}
+		}
+	}
+}
+
+// reconnect 按指数退避重连，直到成功或 ctx 取消
+func (rmq *RabbitMQ) reconnect(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		delay := Backoff(attempt, rmq.ReconnectMin, rmq.ReconnectMax)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if err := rmq.connect(); err != nil {
+			rmq.logger.Error("重连 RabbitMQ 失败", "attempt", attempt+1, "retry_in", delay, logging.Err(err))
+			continue
+		}
+		metrics.AMQPReconnects.Inc()
+		rmq.logger.Info("RabbitMQ 重连成功")
+		return
+	}
+}
+
+func (rmq *RabbitMQ) reopenPublisher(conn *amqp.Connection) error {
+	pubCh, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	rmq.mu.Lock()
+	rmq.pubCh = pubCh
+	rmq.pubOpen.Store(true)
+	rmq.connected.Store(true)
+	rmq.mu.Unlock()
+	return nil
+}
+
+// RabbitMQStatus 连接和通道的当前状态，用于就绪检查
+type RabbitMQStatus struct {
+	ConnectionOpen     bool `json:"connection_open"`
+	PublishChannelOpen bool `json:"publish_channel_open"`
+	// consumerTag -> 消费通道是否已订阅
+	ConsumerChannels map[string]bool `json:"consumer_channels"`
+}
+
+// Status 返回连接、发布通道和各消费通道的状态
+func (rmq *RabbitMQ) Status() RabbitMQStatus {
+	rmq.mu.RLock()
+	conn := rmq.conn
+	rmq.mu.RUnlock()
+
+	status := RabbitMQStatus{
+		ConnectionOpen:     conn != nil && !conn.IsClosed(),
+		PublishChannelOpen: rmq.pubOpen.Load(),
+		ConsumerChannels:   make(map[string]bool),
+	}
+	rmq.subscriptions.Range(func(k, v any) bool {
+		status.ConsumerChannels[k.(string)] = v.(bool)
+		return true
+	})
+	return status
+}
+
+// Subscribed 消费者当前是否已在 broker 上订阅
+func (rmq *RabbitMQ) Subscribed(consumerTag string) bool {
+	v, ok := rmq.subscriptions.Load(consumerTag)
+	return ok && v.(bool)
+}
+
+// openChannel 在当前连接上打开新通道
+func (rmq *RabbitMQ) openChannel() (*amqp.Channel, error) {
+	rmq.mu.RLock()
+	conn := rmq.conn
+	rmq.mu.RUnlock()
+	if conn == nil || conn.IsClosed() {
+		return nil, errNotConnected
+	}
+	ch, err := conn.Channel()
+	if err != nil {
 		return nil, fmt.Errorf("打开通道失败: %w", err)
 	}
-	return &RabbitMQ{
-		Conn:    conn,
-		Channel: ch,
-		Queues:  make(map[string]amqp.Queue),
-	}, nil
+	return ch, nil
 }
 
 // 添加队列存在性检查方法
 func (rmq *RabbitMQ) QueueExists(queue string) bool {
+	rmq.queuesMu.RLock()
+	defer rmq.queuesMu.RUnlock()
 	_, exists := rmq.Queues[queue]
 	return exists
 }
 
-// 声明交换机
-func (rmq *RabbitMQ) DeclareExchange(exchange string) error {
-	err := rmq.Channel.ExchangeDeclare(
+// 交换机类型
+const (
+	ExchangeDirect  = "direct"
+	ExchangeTopic   = "topic"
+	ExchangeFanout  = "fanout"
+	ExchangeHeaders = "headers"
+	// 延迟消息插件 rabbitmq_delayed_message_exchange，需要 x-delayed-type 参数
+	ExchangeDelayed = "x-delayed-message"
+)
+
+func isRoutingKind(kind string) bool {
+	switch kind {
+	case ExchangeDirect, ExchangeTopic, ExchangeFanout, ExchangeHeaders:
+		return true
+	}
+	return false
+}
+
+// 声明交换机，kind 为空时按 direct 处理；
+// 延迟交换机必须在 args 中带上 x-delayed-type
+func (rmq *RabbitMQ) DeclareExchange(exchange, kind string, args amqp.Table) error {
+	return rmq.withTempChannel(func(ch *amqp.Channel) error {
+		return rmq.declareExchangeOn(ch, exchange, kind, args)
+	})
+}
+
+func (rmq *RabbitMQ) declareExchangeOn(ch *amqp.Channel, exchange, kind string, args amqp.Table) error {
+	if kind == "" {
+		kind = ExchangeDirect
+	}
+	if kind == ExchangeDelayed {
+		delayedType, _ := args["x-delayed-type"].(string)
+		if !isRoutingKind(delayedType) {
+			return fmt.Errorf("延迟交换机 %s 的 x-delayed-type 无效: %q", exchange, delayedType)
+		}
+	} else if !isRoutingKind(kind) {
+		return fmt.Errorf("不支持的交换机类型: %s", kind)
+	}
+
+	err := ch.ExchangeDeclare(
 		exchange,
-		"direct",
+		kind,
 		true,
 		false,
 		false,
 		false,
-		nil,
+		args,
 	)
 	if err != nil {
 		return fmt.Errorf("声明交换机失败: %w", err)
 	}
-	log.Printf("✅ 已声明交换机: %s\n", exchange)
+	rmq.logger.Debug("已声明交换机", "exchange", exchange, "kind", kind)
 	return nil
 }
 
 // 声明队列
 func (rmq *RabbitMQ) DeclareQueue(queue string) (amqp.Queue, error) {
-	q, err := rmq.Channel.QueueDeclare(
-		queue,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return q, fmt.Errorf("声明队列失败: %w", err)
-	}
-	log.Printf("✅ 已声明队列: %s\n", queue)
-	return q, nil
+	return rmq.declareQueue(queue, nil)
 }
 
 // 声明队列
 func (rmq *RabbitMQ) DeclareQueueQuorum(queue string) (amqp.Queue, error) {
-	q, err := rmq.Channel.QueueDeclare(
+	return rmq.declareQueue(queue, amqp.Table{
+		"x-queue-type": "quorum",
+	})
+}
+
+func (rmq *RabbitMQ) declareQueue(queue string, args amqp.Table) (amqp.Queue, error) {
+	var q amqp.Queue
+	err := rmq.withTempChannel(func(ch *amqp.Channel) error {
+		var err error
+		q, err = rmq.declareQueueOn(ch, queue, args)
+		return err
+	})
+	return q, err
+}
+
+func (rmq *RabbitMQ) declareQueueOn(ch *amqp.Channel, queue string, args amqp.Table) (amqp.Queue, error) {
+	q, err := ch.QueueDeclare(
 		queue,
 		true,
 		false,
 		false,
 		false,
-		amqp.Table{
-			"x-queue-type": "quorum",
-		},
+		args,
 	)
 	if err != nil {
 		return q, fmt.Errorf("声明队列失败: %w", err)
 	}
-	log.Printf("✅ 已声明队列: %s\n", queue)
+	rmq.queuesMu.Lock()
+	rmq.Queues[queue] = q
+	rmq.queuesMu.Unlock()
+	rmq.logger.Debug("已声明队列", "queue", queue)
 	return q, nil
 }
 
 // 队列绑定交换机
 func (rmq *RabbitMQ) BindQueue(queue, exchange, routingKey string) error {
-	err := rmq.Channel.QueueBind(
+	return rmq.BindQueueWithArgs(queue, exchange, routingKey, nil)
+}
+
+// 队列绑定交换机，args 用于 headers 交换机的匹配条件
+func (rmq *RabbitMQ) BindQueueWithArgs(queue, exchange, routingKey string, args amqp.Table) error {
+	return rmq.withTempChannel(func(ch *amqp.Channel) error {
+		return rmq.bindQueueOn(ch, queue, exchange, routingKey, args)
+	})
+}
+
+func (rmq *RabbitMQ) bindQueueOn(ch *amqp.Channel, queue, exchange, routingKey string, args amqp.Table) error {
+	err := ch.QueueBind(
 		queue,
 		routingKey,
 		exchange,
 		false,
-		nil,
+		args,
 	)
 	if err != nil {
 		return fmt.Errorf("绑定队列失败: %w", err)
 	}
-	log.Printf("✅ 队列 %s 已绑定到交换机 %s（routingKey: %s）\n", queue, exchange, routingKey)
+	rmq.logger.Debug("已绑定队列", "queue", queue, "exchange", exchange, "routing_key", routingKey)
 	return nil
 }
 
-// 发布消息
-func (rmq *RabbitMQ) Publish(exchange, routingKey string, body []byte, delayMs int) error {
-	headers := amqp.Table{"x-delay": delayMs * 1000} // 延迟每秒
-	err := rmq.Channel.Publish(
-		exchange,
-		routingKey,
-		false,
-		false,
-		amqp.Publishing{
-			Headers:      headers,
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("发布消息失败: %w", err)
+// ExchangeCodec 交换机的发布编码，拓扑中未配置时使用 JSON
+func (rmq *RabbitMQ) ExchangeCodec(exchange string) Codec {
+	if codec, ok := rmq.codecs[exchange]; ok {
+		return codec
 	}
-	log.Printf("📤 已向交换机 %s（routingKey: %s）发布消息\n", exchange, routingKey)
-	return nil
+	return jsonCodec
+}
+
+// DeadLetter 把消息连同失败原因转发到队列配置的死信交换机。
+// 原消息头、属性和消息体保持不变，另外带上 x-death-reason 和 x-original-queue；
+// 队列未配置死信交换机时返回 errNoDeadLetter，由调用方 Nack 丢弃。
+func (rmq *RabbitMQ) DeadLetter(ctx context.Context, queue string, d amqp.Delivery, reason string) error {
+	exchange, routingKey, ok := rmq.deadLetterTarget(queue)
+	if !ok {
+		return errNoDeadLetter
+	}
+	// 死信交换机未指定 routingKey 时沿用原消息的 routingKey，与 broker 的行为一致
+	if routingKey == "" {
+		routingKey = d.RoutingKey
+	}
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderDeathReason] = reason
+	headers[HeaderOriginalQueue] = queue
+	return rmq.publish(ctx, exchange, routingKey, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+		DeliveryMode:    amqp.Persistent,
+	})
 }
 
-// 消费消息
-func (rmq *RabbitMQ) Consume(queue string) (<-chan amqp.Delivery, error) {
-	//// 每次只处理10条
-	//if err := rmq.Channel.Qos(10, 0, false); err != nil {
-	//	return nil, fmt.Errorf("设置Qos失败: %w", err)
-	//}
+// 死信消息上附加的消息头
+const (
+	HeaderDeathReason   = "x-death-reason"
+	HeaderOriginalQueue = "x-original-queue"
+)
 
-	msgs, err := rmq.Channel.Consume(
-		queue,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("注册消费者失败: %w", err)
+// deadLetterTarget 从拓扑中查找队列的死信交换机和 routingKey
+func (rmq *RabbitMQ) deadLetterTarget(queue string) (exchange, routingKey string, ok bool) {
+	if rmq.topology == nil {
+		return "", "", false
+	}
+	q, ok := rmq.topology.Queue(queue)
+	if !ok || q.DeadLetterExchange == "" {
+		return "", "", false
 	}
-	log.Printf("📥 开始消费队列: %s\n", queue)
-	return msgs, nil
+	return q.DeadLetterExchange, q.DeadLetterRoutingKey, true
 }
 
-// 关闭连接
-func (rmq *RabbitMQ) Close() {
-	if rmq.Channel != nil {
-		rmq.Channel.Close()
-		log.Println("🔒 已关闭通道")
+// publish 在发布通道上发布一条消息，不等待 broker 确认
+func (rmq *RabbitMQ) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	return rmq.publishWith(ctx, exchange, routingKey, msg, func(msg amqp.Publishing) error {
+		rmq.mu.RLock()
+		pubCh := rmq.pubCh
+		rmq.mu.RUnlock()
+		if pubCh == nil || !rmq.connected.Load() {
+			return errNotConnected
+		}
+		return pubCh.Publish(exchange, routingKey, false, false, msg)
+	})
+}
+
+// PublishConfirmed 发布一条消息并等待 broker 确认，返回 nil 表示 broker 已接收。
+// 使用单独开启了 publisher confirms 的通道，调用串行执行；ctx 到期时放弃等待并重建通道，
+// 消息可能已被接收，调用方重试会产生重复消息。
+func (rmq *RabbitMQ) PublishConfirmed(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	return rmq.publishWith(ctx, exchange, routingKey, msg, func(msg amqp.Publishing) error {
+		return rmq.confirm.publish(ctx, rmq, exchange, routingKey, msg)
+	})
+}
+
+// publishWith 补充 trace 消息头、压缩消息体后调用 send 发布，并记录发布失败指标
+func (rmq *RabbitMQ) publishWith(ctx context.Context, exchange, routingKey string, msg amqp.Publishing, send func(amqp.Publishing) error) (err error) {
+	rmq.publishing.Add(1)
+	defer rmq.publishing.Done()
+
+	ctx, span := tracing.Tracer().Start(ctx, exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+		))
+	defer func() {
+		if err != nil {
+			metrics.PublishFailures.WithLabelValues(exchange).Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("发布消息失败: %w", err)
 	}
-	if rmq.Conn != nil {
-		rmq.Conn.Close()
-		log.Println("🔒 已关闭连接")
+
+	if err := rmq.compressPublishing(&msg); err != nil {
+		return err
 	}
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	// trace 上下文随消息头传给下游消费者
+	tracing.InjectAMQP(ctx, msg.Headers)
+
+	if err = send(msg); err != nil {
+		return fmt.Errorf("发布消息失败: %w", err)
+	}
+	rmq.logger.DebugContext(ctx, "已发布消息", "exchange", exchange, "routing_key", routingKey)
+	return nil
 }
-func forceInitRabbitMQ(url string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
+
+// 等待进行中的发布完成，超时返回 ctx 的错误
+func (rmq *RabbitMQ) WaitPublishes(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		rmq.publishing.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待发布完成超时: %w", ctx.Err())
 	}
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, err
+}
+
+// 消费消息。
+// 每次调用占用一个独立通道并按 prefetch 设置 Qos；通道或连接断开后按退避自动重新订阅，
+// 返回的 deliveries 在整个生命周期内保持不变。
+// ctx 取消后向 broker 取消订阅，已投递的消息仍可确认，之后 deliveries 关闭。
+func (rmq *RabbitMQ) Consume(ctx context.Context, queue, consumerTag string, prefetch int) (<-chan amqp.Delivery, error) {
+	if consumerTag == "" {
+		return nil, errors.New("consumerTag 不能为空")
 	}
-	return &RabbitMQ{Conn: conn, Channel: ch, Queues: make(map[string]amqp.Queue)}, nil
+	out := make(chan amqp.Delivery)
+	go rmq.runConsumer(ctx, queue, consumerTag, prefetch, out)
+	return out, nil
 }
-func MonitorAndReconnect(ctx context.Context, url string, onReconnect func(*RabbitMQ)) {
-	for {
-		rmq, err := InitRabbitMQ(url)
+
+func (rmq *RabbitMQ) runConsumer(ctx context.Context, queue, consumerTag string, prefetch int, out chan<- amqp.Delivery) {
+	defer close(out)
+	rmq.subscriptions.Store(consumerTag, false)
+	defer rmq.subscriptions.Delete(consumerTag)
+
+	for attempt := 0; ; attempt++ {
+		if ctx.Err() != nil {
+			return
+		}
+		ch, deliveries, err := rmq.subscribe(queue, consumerTag, prefetch)
 		if err != nil {
-			log.Printf("❌ 初始化 RabbitMQ 失败: %v", err)
-			time.Sleep(3 * time.Second)
+			delay := Backoff(attempt, rmq.ReconnectMin, rmq.ReconnectMax)
+			rmq.logger.Error("订阅队列失败", "queue", queue, "retry_in", delay, logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
 			continue
 		}
-		if onReconnect != nil {
-			onReconnect(rmq)
+		attempt = -1
+		rmq.subscriptions.Store(consumerTag, true)
+		rmq.logger.Info("已订阅队列", "queue", queue, "consumer", consumerTag, "prefetch", prefetch)
+
+		finished := rmq.forward(ctx, ch, consumerTag, deliveries, out)
+		rmq.subscriptions.Store(consumerTag, false)
+		if finished {
+			return
 		}
+		rmq.logger.Warn("消费通道已关闭，重新订阅", "queue", queue)
+		ch.Close()
+	}
+}
 
-		errChan := make(chan *amqp.Error, 1)
-		rmq.Conn.NotifyClose(errChan)
+func (rmq *RabbitMQ) subscribe(queue, consumerTag string, prefetch int) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	ch, err := rmq.openChannel()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		ch.Close()
+		return nil, nil, fmt.Errorf("设置Qos失败: %w", err)
+	}
+	deliveries, err := ch.Consume(
+		queue,
+		consumerTag,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		ch.Close()
+		return nil, nil, fmt.Errorf("注册消费者失败: %w", err)
+	}
+	return ch, deliveries, nil
+}
 
+// forward 把通道上的消息转发给 out。
+// 返回 true 表示 ctx 已取消、订阅已结束；false 表示通道被关闭，需要重新订阅。
+func (rmq *RabbitMQ) forward(ctx context.Context, ch *amqp.Channel, consumerTag string, deliveries <-chan amqp.Delivery, out chan<- amqp.Delivery) bool {
+	for {
 		select {
 		case <-ctx.Done():
-			rmq.Close()
-			log.Println("RabbitMQ 监控退出")
-			return
-		case err := <-errChan:
-			log.Printf("⚠️ RabbitMQ 连接关闭: %v，准备重连", err)
-			rmq.Close()
-			time.Sleep(3 * time.Second)
+			// 停止投递后把已预取的消息交给 worker 处理或退回，通道保持打开以便确认
+			if err := ch.Cancel(consumerTag, false); err != nil {
+				rmq.logger.Error("取消消费者失败", "consumer", consumerTag, logging.Err(err))
+				return true
+			}
+			rmq.logger.Info("已取消消费者", "consumer", consumerTag)
+			for d := range deliveries {
+				out <- d
+			}
+			return true
+		case d, ok := <-deliveries:
+			if !ok {
+				return false
+			}
+			out <- d
 		}
 	}
 }
+
+// 关闭连接
+func (rmq *RabbitMQ) Close() {
+	rmq.connected.Store(false)
+	rmq.pubOpen.Store(false)
+
+	rmq.mu.Lock()
+	defer rmq.mu.Unlock()
+	if rmq.pubCh != nil {
+		rmq.pubCh.Close()
+		rmq.logger.Info("已关闭通道")
+	}
+	if rmq.conn != nil {
+		rmq.conn.Close()
+		rmq.logger.Info("已关闭连接")
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"trade-solution/ordercenter/config"
+
+	"github.com/streadway/amqp"
+)
+
+// 默认拓扑的队列参数与已部署的队列保持一致，死信由 policy 设置，不出现在队列参数里
+func TestQueueArgsDefaultTopology(t *testing.T) {
+	for _, qc := range config.DefaultTopology().Queues {
+		want := amqp.Table{"x-queue-type": "quorum"}
+		if got := queueArgs(qc); !reflect.DeepEqual(got, want) {
+			t.Errorf("队列 %s 参数 = %v, want %v", qc.Name, got, want)
+		}
+	}
+}
+
+func TestQueueArgs(t *testing.T) {
+	qc := config.QueueConfig{
+		Name:                 "q",
+		DeadLetterExchange:   config.DeadLetterExchange,
+		DeadLetterRoutingKey: "q",
+		MessageTTLMs:         60000,
+		Args:                 map[string]interface{}{"x-max-length": float64(1000)},
+	}
+	want := amqp.Table{"x-message-ttl": int64(60000), "x-max-length": int64(1000)}
+	if got := queueArgs(qc); !reflect.DeepEqual(got, want) {
+		t.Fatalf("queueArgs = %v, want %v", got, want)
+	}
+	if got := queueArgs(config.QueueConfig{Name: "q"}); got != nil {
+		t.Fatalf("无参数的队列 queueArgs = %v, want nil", got)
+	}
+}